@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/AlexanderEkdahl/rope/version"
@@ -11,7 +12,12 @@ import (
 
 // TODO: Add update parameter for when to use the latest version of transitive
 // dependency.
-func add(timeout time.Duration, packages []string) error {
+//
+// Requirements not satisfied by the selected versions are reported and cause
+// add to fail without modifying the project if strict is true. If
+// allowPrereleases is true pre-releases of the added packages may be
+// selected.
+func add(timeout time.Duration, strict, allowPrereleases bool, packages []string) error {
 	ctx := context.Background()
 	if timeout > 0 {
 		var cancel context.CancelFunc
@@ -28,23 +34,28 @@ func add(timeout time.Duration, packages []string) error {
 		return err
 	}
 
-	// index := &Index{url: DefaultIndex}
-	index := &PyPI{}
+	var requirements []*version.Dependency
+	var prereleases []string
 	for _, p := range packages {
 		d, err := version.ParseDependency(p)
 		if err != nil {
 			return err
 		}
+		requirements = append(requirements, d)
+		if allowPrereleases {
+			prereleases = append(prereleases, d.Name)
+		}
+	}
 
+	index, err := project.packageIndex(prereleases)
+	if err != nil {
+		return err
+	}
+	for _, d := range requirements {
 		if len(d.Versions) > 1 {
 			return fmt.Errorf("expected at most a single version, got: %d", len(d.Versions))
 		}
 
-		if len(d.Extras) > 0 {
-			// TODO: Support extras
-			return fmt.Errorf("extras not supported")
-		}
-
 		var version version.Version
 		if len(d.Versions) > 0 {
 			version = d.Versions[0].Version
@@ -55,8 +66,10 @@ func add(timeout time.Duration, packages []string) error {
 			return fmt.Errorf("finding '%s-%s': %w", d.Name, version, err)
 		}
 		project.Dependencies = append(project.Dependencies, Dependency{
-			Name:    p.Name(),
-			Version: p.Version(),
+			Name:             p.Name(),
+			Version:          p.Version(),
+			Extras:           normalizeExtras(d.Extras),
+			AllowPrereleases: allowPrereleases,
 		})
 	}
 
@@ -64,19 +77,33 @@ func add(timeout time.Duration, packages []string) error {
 	if err != nil {
 		return fmt.Errorf("failed version selection: %w", err)
 	}
+	if err := checkConflicts(ctx, os.Stderr, project.Dependencies, list, index, strict); err != nil {
+		return err
+	}
 
+	var installed []LockedArtifact
 	for _, d := range list {
 		p, err := index.FindPackage(ctx, d.Name, d.Version)
 		if err != nil {
 			return fmt.Errorf("failed to find package after version selection: %w", err)
 		}
+		if err := project.checkLock(p, false); err != nil {
+			return err
+		}
 
 		// TODO: This function need to find the package AGAIN? doesn't make sense
 		if _, err := p.Install(ctx); err != nil {
 			return fmt.Errorf("installing '%s-%s': %w", d.Name, d.Version, err)
 		}
+
+		a, err := lockedArtifact(p)
+		if err != nil {
+			return err
+		}
+		installed = append(installed, a)
 	}
 
 	project.Dependencies = minimalRequirements
+	project.updateLock(list, installed)
 	return WriteRopefile(project, ropefilePath)
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UnsafePathError is returned when an archive member would be extracted
+// outside of the destination directory or is of a type that is not allowed
+// in a package archive.
+type UnsafePathError struct {
+	// Member is the name of the offending member of the archive.
+	Member string
+	Reason string
+}
+
+func (e *UnsafePathError) Error() string {
+	return fmt.Sprintf("unsafe archive member '%s': %s", e.Member, e.Reason)
+}
+
+// safeJoin returns the path where the archive member should be extracted
+// within root. An UnsafePathError is returned if the member name is absolute,
+// traverses outside of root or would be extracted through a symbolic link
+// already extracted within root.
+func safeJoin(root, member string) (string, error) {
+	// Archives created on Windows may use backslash as separator.
+	name := strings.ReplaceAll(member, "\\", "/")
+	if name == "" {
+		return "", &UnsafePathError{Member: member, Reason: "empty path"}
+	}
+	if strings.HasPrefix(name, "/") || filepath.IsAbs(name) || filepath.VolumeName(filepath.FromSlash(name)) != "" {
+		return "", &UnsafePathError{Member: member, Reason: "absolute path"}
+	}
+	for _, element := range strings.Split(name, "/") {
+		if element == ".." {
+			return "", &UnsafePathError{Member: member, Reason: "path traversal"}
+		}
+	}
+
+	target := filepath.Join(root, filepath.FromSlash(name))
+	if !withinRoot(root, target) {
+		return "", &UnsafePathError{Member: member, Reason: "path traversal"}
+	}
+	if err := checkSymlinks(root, target, member); err != nil {
+		return "", err
+	}
+
+	return target, nil
+}
+
+// checkSymlinks returns an UnsafePathError if the target or any of its parent
+// directories within root is an existing symbolic link. Members are never
+// extracted through symbolic links as a chain of links, e.g. 'a -> ..' and
+// 'a/b -> ..', may resolve outside of root even though every link appears
+// to be within root.
+func checkSymlinks(root, target, member string) error {
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return &UnsafePathError{Member: member, Reason: "path traversal"}
+	}
+
+	current := root
+	for _, element := range strings.Split(rel, string(filepath.Separator)) {
+		if element == "." {
+			continue
+		}
+		current = filepath.Join(current, element)
+
+		fi, err := os.Lstat(current)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			rel, _ := filepath.Rel(root, current)
+			return &UnsafePathError{Member: member, Reason: fmt.Sprintf("'%s' is a symbolic link", filepath.ToSlash(rel))}
+		}
+	}
+
+	return nil
+}
+
+// safeSymlink validates that the symbolic link member pointing to linkname
+// does not escape root and returns the path where the link should be created.
+// Symbolic links are resolved relative to the directory containing the link.
+func safeSymlink(root, member, linkname string) (string, error) {
+	target, err := safeJoin(root, member)
+	if err != nil {
+		return "", err
+	}
+
+	if !symlinkWithinRoot(root, target, linkname) {
+		return "", &UnsafePathError{Member: member, Reason: fmt.Sprintf("symbolic link to '%s' escapes the destination", linkname)}
+	}
+
+	return target, nil
+}
+
+// symlinkWithinRoot returns true if a symbolic link created at path pointing
+// to linkname resolves to a location within root. The link is resolved one
+// element at a time and links traversing other symbolic links are refused
+// as 'link/..' does not resolve to the directory containing link.
+func symlinkWithinRoot(root, path, linkname string) bool {
+	name := strings.ReplaceAll(linkname, "\\", "/")
+	link := filepath.FromSlash(name)
+	if linkname == "" || filepath.IsAbs(link) || strings.HasPrefix(name, "/") || filepath.VolumeName(link) != "" {
+		return false
+	}
+
+	current := filepath.Dir(path)
+	elements := strings.Split(name, "/")
+	for i, element := range elements {
+		switch element {
+		case "", ".":
+			continue
+		case "..":
+			current = filepath.Dir(current)
+		default:
+			current = filepath.Join(current, element)
+			if i < len(elements)-1 {
+				if fi, err := os.Lstat(current); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+					return false
+				}
+			}
+		}
+		if !withinRoot(root, current) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkMode returns an UnsafePathError if the archive member is neither a
+// regular file, directory or symbolic link.
+func checkMode(member string, mode os.FileMode) error {
+	switch {
+	case mode&(os.ModeDevice|os.ModeCharDevice) != 0:
+		return &UnsafePathError{Member: member, Reason: "device file"}
+	case mode&(os.ModeNamedPipe|os.ModeSocket|os.ModeIrregular) != 0:
+		return &UnsafePathError{Member: member, Reason: "special file"}
+	default:
+		return nil
+	}
+}
+
+func withinRoot(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSafeJoin(t *testing.T) {
+	root := filepath.FromSlash("/tmp/root")
+
+	testCases := []struct {
+		member string
+		valid  bool
+	}{
+		{"example/__init__.py", true},
+		{"./example/__init__.py", true},
+		{"example/../example/__init__.py", false},
+		{"../evil.py", false},
+		{"example/../../evil.py", false},
+		{"..\\evil.py", false},
+		{"/etc/passwd", false},
+		{"\\etc\\passwd", false},
+		{"", false},
+	}
+
+	for _, tc := range testCases {
+		target, err := safeJoin(root, tc.member)
+		if tc.valid && err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.member, err)
+		} else if !tc.valid {
+			var unsafePathErr *UnsafePathError
+			if !errors.As(err, &unsafePathErr) {
+				t.Fatalf("%s: expected UnsafePathError, got: %v (%s)", tc.member, err, target)
+			}
+			if unsafePathErr.Member != tc.member {
+				t.Fatalf("%s: unexpected member, got: %s", tc.member, unsafePathErr.Member)
+			}
+		}
+	}
+}
+
+func TestSafeSymlink(t *testing.T) {
+	root := filepath.FromSlash("/tmp/root")
+
+	testCases := []struct {
+		member   string
+		linkname string
+		valid    bool
+	}{
+		{"example/link", "target", true},
+		{"example/link", "../other/target", true},
+		{"example/link", "../../target", false},
+		{"link", "..", false},
+		{"link", "/etc/passwd", false},
+		{"../link", "target", false},
+	}
+
+	for _, tc := range testCases {
+		_, err := safeSymlink(root, tc.member, tc.linkname)
+		var unsafePathErr *UnsafePathError
+		if tc.valid && err != nil {
+			t.Fatalf("%s -> %s: unexpected error: %v", tc.member, tc.linkname, err)
+		} else if !tc.valid && !errors.As(err, &unsafePathErr) {
+			t.Fatalf("%s -> %s: expected UnsafePathError, got: %v", tc.member, tc.linkname, err)
+		}
+	}
+}
+
+func TestChainedSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+
+	// Every link appears to be within root on its own but 'd1/l/l2'
+	// resolves to the parent of root.
+	archive := buildTestTarGz(t, []testTarEntry{
+		{name: "d1/l", typeflag: tar.TypeSymlink, linkname: ".."},
+		{name: "d1/l/l2", typeflag: tar.TypeSymlink, linkname: ".."},
+		{name: "d1/l/l2/evil", typeflag: tar.TypeReg, data: "evil"},
+	})
+	err := (&Sdist{}).untar(bytes.NewReader(archive), root)
+
+	var unsafePathErr *UnsafePathError
+	if !errors.As(err, &unsafePathErr) {
+		t.Fatalf("expected UnsafePathError, got: %v", err)
+	}
+	if unsafePathErr.Member != "d1/l/l2" {
+		t.Fatalf("unexpected member, got: %s, want: d1/l/l2", unsafePathErr.Member)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected file outside of destination to not exist")
+	}
+
+	// Links traversing other links are refused.
+	if symlinkWithinRoot(root, filepath.Join(root, "d2", "link"), "../d1/l/../evil") {
+		t.Fatalf("expected link through 'd1/l' to be refused")
+	}
+}
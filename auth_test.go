@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AlexanderEkdahl/rope/version"
+)
+
+func TestParseNetrc(t *testing.T) {
+	machines := parseNetrc(`
+machine example.com login alice password secret
+macdef init
+machine ignored.com login x password y
+
+machine other.com
+	login bob
+	account ignored
+	password hunter2
+default login anonymous password guest
+`)
+
+	expected := map[string]credential{
+		"example.com": {username: "alice", password: "secret"},
+		"other.com":   {username: "bob", password: "hunter2"},
+		"":            {username: "anonymous", password: "guest"},
+	}
+	if len(machines) != len(expected) {
+		t.Fatalf("unexpected machines, got: %v", machines)
+	}
+	for machine, c := range expected {
+		if machines[machine] != c {
+			t.Fatalf("unexpected credentials for '%s', got: %v, want: %v", machine, machines[machine], c)
+		}
+	}
+}
+
+func TestAuthenticatedIndex(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		authorized := ok && username == "alice" && password == "secret" ||
+			r.Header.Get("Authorization") == "Bearer token"
+		if !authorized {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, `<html><body><a href="../../files/private-1.0.tar.gz">private-1.0.tar.gz</a></body></html>`)
+	}))
+	defer server.Close()
+
+	oldAuth, oldClient, oldCache := auth, client, cache
+	defer func() {
+		auth, client, cache = oldAuth, oldClient, oldCache
+	}()
+	cache = &Cache{Temporary: true}
+	defer cache.Close()
+
+	netrc := filepath.Join(t.TempDir(), ".netrc")
+	host := strings.TrimPrefix(server.URL, "http://")
+	if err := ioutil.WriteFile(netrc, []byte(fmt.Sprintf("machine %s login alice password secret\n", strings.Split(host, ":")[0])), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := map[string]struct {
+		url   string
+		env   map[string]string
+		netrc string
+		ok    bool
+	}{
+		"unauthenticated": {
+			url: server.URL + "/simple",
+		},
+		"userinfo": {
+			url: strings.Replace(server.URL, "http://", "http://alice:secret@", 1) + "/simple",
+			ok:  true,
+		},
+		"environment basic": {
+			url: server.URL + "/simple",
+			env: map[string]string{"ROPE_INDEX_MY_INDEX_USERNAME": "alice", "ROPE_INDEX_MY_INDEX_PASSWORD": "secret"},
+			ok:  true,
+		},
+		"environment token": {
+			url: server.URL + "/simple",
+			env: map[string]string{"ROPE_INDEX_MY_INDEX_TOKEN": "token"},
+			ok:  true,
+		},
+		"netrc": {
+			url:   server.URL + "/simple",
+			netrc: netrc,
+			ok:    true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tc.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			netrcPath := tc.netrc
+			if netrcPath == "" {
+				netrcPath = filepath.Join(t.TempDir(), "missing")
+			}
+			auth = &Auth{NetrcPath: netrcPath}
+			client = &http.Client{Transport: auth}
+
+			project := &Project{
+				Indexes: []IndexConfig{{Name: "my-index", Type: IndexTypeSimple, URL: tc.url}},
+			}
+			index, err := project.PackageIndex()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			p, err := index.FindPackage(context.Background(), "private", version.Version{})
+			if !tc.ok {
+				if err == nil || !strings.Contains(err.Error(), "401") {
+					t.Fatalf("expected unauthorized error, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			sdist := p.(*Sdist)
+			if sdist.url != server.URL+"/files/private-1.0.tar.gz" {
+				t.Fatalf("unexpected url: %s", sdist.url)
+			}
+			if strings.Contains(sdist.url, "secret") {
+				t.Fatalf("credentials leaked into url: %s", sdist.url)
+			}
+		})
+	}
+}
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/AlexanderEkdahl/rope/version"
 )
@@ -23,7 +31,11 @@ wheels after download.
 
 <os.UserCacheDir()> / <cacheVersion> / <Index> / <NormalizedPackageName> / <file>
 
-TODO: Add <Index> to cache path.
+<Index> identifies the package repository the wheel was downloaded from(see
+indexKey). The index.json file of every package records the sha256 digest of
+every cached wheel which is verified the first time a wheel is retrieved by a
+process.
+
 TODO: The cache should contain rope metadata files(name, version, dependencies, checksum)
 inspired by https://github.com/rust-lang/crates.io-index
 TODO: Automatically disable caching when running in docker(by checking existence of /.dockerenv)
@@ -33,7 +45,7 @@ unless explicitly enabled.
 
 // cacheVersion is the version of the cache. If the cache is ever changed in
 // a backward incompatible manner this value will be changed.
-const cacheVersion = "0"
+const cacheVersion = "1"
 
 // Cache is responsible for caching package package downloads and built
 // source distributions.
@@ -46,12 +58,17 @@ type Cache struct {
 
 	once sync.Once
 	err  error
+
+	mu sync.Mutex
+	// verified holds the paths and digests of the wheels verified by this
+	// process to avoid hashing large wheels on every retrieval.
+	verified map[string]bool
 }
 
-// GetWheel searches the cache for the package identified by name and the provided version.
-// If no cached entry can be found nil is returned.
-// TODO: Full URL from the index should be part of the cache path.
-func (c *Cache) GetWheel(name string, v version.Version) (*Wheel, error) {
+// GetWheel searches the cache for the package identified by name and the
+// provided version downloaded from index. If no cached entry can be found nil
+// is returned. Entries failing verification are evicted from the cache.
+func (c *Cache) GetWheel(index, name string, v version.Version) (*Wheel, error) {
 	if v.Unspecified() {
 		return nil, nil
 	}
@@ -62,77 +79,453 @@ func (c *Cache) GetWheel(name string, v version.Version) (*Wheel, error) {
 		return nil, c.err
 	}
 
-	ciFile, err := os.Open(filepath.Join(c.getPath(name), "index.json"))
-	if errors.Is(err, os.ErrNotExist) {
-		fmt.Printf("⛔️\n")
-		return nil, nil
-	} else if err != nil {
-		return nil, fmt.Errorf("opening cache index: %w", err)
+	dir := c.getPath(index, name)
+	cis, err := readCacheIndex(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return nil, err
 	}
-	defer ciFile.Close()
-
-	dec := json.NewDecoder(ciFile)
-	for {
-		var ci cacheIndex
-		err := dec.Decode(&ci)
-		if err == io.EOF {
-			fmt.Printf("⛔️\n")
-			return nil, nil
-		} else if err != nil {
-			return nil, fmt.Errorf("decoding cache index line: %w", err)
-		}
 
+	for _, ci := range cis {
 		whl, err := ParseWheelFilename(ci.Filename)
 		if err != nil {
 			return nil, err
 		}
-		whl.Path = filepath.Join(c.getPath(name), ci.Filename)
+		whl.Path = filepath.Join(dir, ci.Filename)
+		whl.Index = ci.Index
+		whl.URL = ci.URL
+		whl.Source = ci.Source
+		whl.Hashes = map[string]string{"sha256": ci.Sum}
 		whl.RequiresDist = ci.RequiresDist
 		whl.RequiresPython = ci.RequiresPython
 
-		if whl.version.Equal(v) && whl.Compatible(env) {
-			fmt.Printf("✅\n")
-			return whl, nil
+		if !whl.version.Equal(v) || !whl.Compatible(env) {
+			continue
+		}
+
+		if !c.isVerified(whl.Path, ci.Sum) {
+			if err := verifySum(whl.Path, ci.Sum); err != nil {
+				fmt.Printf("❌\n")
+				fmt.Fprintf(os.Stderr, "evicting %s from cache: %v\n", ci.Filename, err)
+				if err := c.removeFiles(dir, map[string]bool{ci.Filename: true}); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			c.setVerified(whl.Path, ci.Sum)
+		}
+
+		fmt.Printf("✅\n")
+		// Record the time of use to allow pruning rarely used entries.
+		now := time.Now()
+		if err := os.Chtimes(whl.Path, now, now); err != nil {
+			return nil, err
 		}
+		return whl, nil
 	}
+
+	fmt.Printf("⛔️\n")
+	return nil, nil
 }
 
-// AddWheel moves the Python Wheel located at path to the cache.
-// TODO: Full URL from the index should be part of the cache path.
+// AddWheel moves the Python Wheel located at path to the cache. If the wheel
+// has already been added, e.g. by a concurrent process, the file at path is
+// removed and the cached wheel is used.
 func (c *Cache) AddWheel(w *Wheel, path string) (string, error) {
 	c.once.Do(c.setup)
+	if c.err != nil {
+		return "", c.err
+	}
+
+	sum, err := fileSum(path)
+	if err != nil {
+		return "", err
+	}
 
-	if err := os.MkdirAll(c.getPath(w.name), 0777); err != nil {
+	dir := c.getPath(w.Index, w.name)
+	if err := os.MkdirAll(dir, 0777); err != nil {
 		return "", fmt.Errorf("creating cache directory: %w", err)
 	}
 
-	ciFile, err := os.OpenFile(filepath.Join(c.getPath(w.name), "index.json"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0)
+	lock, err := lockFile(filepath.Join(dir, "index.lock"))
 	if err != nil {
-		return "", fmt.Errorf("opening cache index: %w", err)
+		return "", fmt.Errorf("locking cache index: %w", err)
 	}
-	defer ciFile.Close()
+	defer lock.Unlock()
 
-	enc := json.NewEncoder(ciFile)
-	enc.SetEscapeHTML(false)
+	indexPath := filepath.Join(dir, "index.json")
+	cis, err := readCacheIndex(indexPath)
+	if err != nil {
+		return "", err
+	}
 
-	if err := enc.Encode(cacheIndex{
-		Filename:       w.filename,
-		RequiresDist:   w.RequiresDist,
-		RequiresPython: w.RequiresPython,
-	}); err != nil {
-		return "", fmt.Errorf("encoding cache index line: %w", err)
+	newpath := filepath.Join(dir, w.filename)
+	for _, ci := range cis {
+		if ci.Filename != w.filename {
+			continue
+		}
+		if _, err := os.Stat(newpath); err == nil {
+			os.Remove(path)
+			return newpath, nil
+		}
+		// The file of the duplicate entry is missing; replace it.
 	}
 
-	newpath := filepath.Join(c.getPath(w.name), w.filename)
 	if err := os.Rename(path, newpath); err != nil {
 		return "", fmt.Errorf("moving item to cache: %w", err)
 	}
 
+	kept := make([]cacheIndex, 0, len(cis)+1)
+	for _, ci := range cis {
+		if ci.Filename != w.filename {
+			kept = append(kept, ci)
+		}
+	}
+	c.setVerified(newpath, hex.EncodeToString(sum))
+	kept = append(kept, cacheIndex{
+		Filename:       w.filename,
+		Sum:            hex.EncodeToString(sum),
+		Index:          w.Index,
+		URL:            redactURL(w.URL),
+		Source:         w.Source,
+		RequiresDist:   w.RequiresDist,
+		RequiresPython: w.RequiresPython,
+	})
+	if err := writeCacheIndex(indexPath, kept); err != nil {
+		return "", err
+	}
+
 	return newpath, nil
 }
 
-func (c *Cache) getPath(name string) string {
-	return filepath.Join(c.Path, cacheVersion, NormalizePackageName(name))
+func (c *Cache) isVerified(path, sum string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.verified[path+"@"+sum]
+}
+
+func (c *Cache) setVerified(path, sum string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.verified == nil {
+		c.verified = make(map[string]bool)
+	}
+	c.verified[path+"@"+sum] = true
+}
+
+// CacheEntry describes a single wheel stored in the cache.
+type CacheEntry struct {
+	Name     string
+	Filename string
+	Path     string
+	// Index is the URL of the package repository the wheel was downloaded
+	// from. Empty for wheels built from source distributions without an index.
+	Index          string
+	Sum            string
+	Size           int64
+	ModTime        time.Time
+	RequiresDist   []string
+	RequiresPython string
+}
+
+// Entries returns every wheel recorded in the cache sorted by package name
+// and filename. Entries recorded in the cache index whose file is missing are
+// returned with a size of -1.
+func (c *Cache) Entries() ([]CacheEntry, error) {
+	c.once.Do(c.setup)
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	indexes, err := filepath.Glob(filepath.Join(c.Path, cacheVersion, "*", "*", "index.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []CacheEntry
+	for _, indexPath := range indexes {
+		cis, err := readCacheIndex(indexPath)
+		if err != nil {
+			return nil, err
+		}
+
+		dir := filepath.Dir(indexPath)
+		for _, ci := range cis {
+			entry := CacheEntry{
+				Name:           filepath.Base(dir),
+				Filename:       ci.Filename,
+				Path:           filepath.Join(dir, ci.Filename),
+				Index:          ci.Index,
+				Sum:            ci.Sum,
+				Size:           -1,
+				RequiresDist:   ci.RequiresDist,
+				RequiresPython: ci.RequiresPython,
+			}
+
+			fi, err := os.Stat(entry.Path)
+			if err == nil {
+				entry.Size = fi.Size()
+				entry.ModTime = fi.ModTime()
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Filename < entries[j].Filename
+	})
+	return entries, nil
+}
+
+// Remove removes the provided entries from the cache index and deletes the
+// associated files.
+func (c *Cache) Remove(entries []CacheEntry) error {
+	c.once.Do(c.setup)
+	if c.err != nil {
+		return c.err
+	}
+
+	remove := make(map[string]map[string]bool)
+	for _, e := range entries {
+		dir := filepath.Dir(e.Path)
+		if remove[dir] == nil {
+			remove[dir] = make(map[string]bool)
+		}
+		remove[dir][e.Filename] = true
+	}
+
+	for dir, filenames := range remove {
+		if err := c.removeFiles(dir, filenames); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeFiles removes the files from the cache directory dir of a single
+// package.
+func (c *Cache) removeFiles(dir string, filenames map[string]bool) error {
+	lock, err := lockFile(filepath.Join(dir, "index.lock"))
+	if err != nil {
+		return fmt.Errorf("locking cache index: %w", err)
+	}
+	defer lock.Unlock()
+
+	indexPath := filepath.Join(dir, "index.json")
+	cis, err := readCacheIndex(indexPath)
+	if err != nil {
+		return err
+	}
+
+	kept := make([]cacheIndex, 0, len(cis))
+	for _, ci := range cis {
+		if !filenames[ci.Filename] {
+			kept = append(kept, ci)
+		}
+	}
+	if err := writeCacheIndex(indexPath, kept); err != nil {
+		return err
+	}
+
+	for filename := range filenames {
+		if err := os.Remove(filepath.Join(dir, filename)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing cached file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// RemoveStale removes the directories of earlier cache versions which are no
+// longer read after cacheVersion has been changed. The removed directories are
+// returned.
+func (c *Cache) RemoveStale() ([]string, error) {
+	c.once.Do(c.setup)
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	var removed []string
+	for _, dir := range []string{c.Path, filepath.Join(c.Path, "http")} {
+		fis, err := ioutil.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return removed, err
+		}
+
+		for _, fi := range fis {
+			if !fi.IsDir() || fi.Name() == cacheVersion || !isCacheVersion(fi.Name()) {
+				continue
+			}
+			path := filepath.Join(dir, fi.Name())
+			if err := removeAll(path); err != nil {
+				return removed, err
+			}
+			removed = append(removed, path)
+		}
+	}
+
+	return removed, nil
+}
+
+// isCacheVersion returns true if name is a possible value of cacheVersion.
+func isCacheVersion(name string) bool {
+	for _, r := range name {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// Clean removes every file in the cache including earlier cache versions.
+func (c *Cache) Clean() error {
+	c.once.Do(c.setup)
+	if c.err != nil {
+		return c.err
+	}
+
+	return removeAll(c.Path)
+}
+
+// Verify checks the sha256 digest of the wheel associated with the cache
+// entry and reads every file in it. The zip reader verifies the CRC-32
+// checksum of every file as it is read.
+func (e *CacheEntry) Verify() error {
+	if e.Size < 0 {
+		return fmt.Errorf("file missing")
+	}
+	if err := verifySum(e.Path, e.Sum); err != nil {
+		return err
+	}
+
+	whlFile, err := zip.OpenReader(e.Path)
+	if err != nil {
+		return err
+	}
+	defer whlFile.Close()
+
+	for _, file := range whlFile.File {
+		f, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Name, err)
+		}
+		_, err = io.Copy(ioutil.Discard, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func readCacheIndex(path string) ([]cacheIndex, error) {
+	ciFile, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("opening cache index: %w", err)
+	}
+	defer ciFile.Close()
+
+	// Indexes written by earlier versions of rope may contain duplicate
+	// entries; only the first entry of every file is kept.
+	var cis []cacheIndex
+	seen := make(map[string]bool)
+	dec := json.NewDecoder(ciFile)
+	for {
+		var ci cacheIndex
+		err := dec.Decode(&ci)
+		if err == io.EOF {
+			return cis, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("decoding cache index line: %w", err)
+		}
+		if seen[ci.Filename] {
+			continue
+		}
+		seen[ci.Filename] = true
+		cis = append(cis, ci)
+	}
+}
+
+func writeCacheIndex(path string, cis []cacheIndex) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for _, ci := range cis {
+		if err := enc.Encode(ci); err != nil {
+			return fmt.Errorf("encoding cache index line: %w", err)
+		}
+	}
+
+	// The index is replaced atomically as it is read without holding the lock.
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "index.json.tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *Cache) getPath(index, name string) string {
+	return filepath.Join(c.Path, cacheVersion, indexKey(index), NormalizePackageName(name))
+}
+
+// indexKey returns the name of the cache directory of the package repository
+// identified by the URL index. The key is made up of the host of the URL to
+// make the cache easier to inspect and a digest of the full URL.
+func indexKey(index string) string {
+	if index == "" {
+		return "local"
+	}
+
+	host := "index"
+	if u, err := url.Parse(index); err == nil && u.Host != "" {
+		host = strings.NewReplacer(":", "_").Replace(u.Host)
+	}
+	sum := sha256.Sum256([]byte(index))
+
+	return fmt.Sprintf("%s-%x", host, sum[:8])
+}
+
+// verifySum returns an error if the sha256 digest of the file at path does
+// not match the hex encoded digest expected.
+func verifySum(path, expected string) error {
+	if expected == "" {
+		return fmt.Errorf("missing sha256 digest")
+	}
+
+	sum, err := fileSum(path)
+	if err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(sum); actual != expected {
+		return fmt.Errorf("checksum mismatch, got: %s, expected: %s", actual, expected)
+	}
+
+	return nil
 }
 
 func (c *Cache) setup() {
@@ -166,7 +559,7 @@ func (c *Cache) Close() error {
 		setup = false
 	})
 	if setup && c.Temporary {
-		return os.RemoveAll(c.Path)
+		return removeAll(c.Path)
 	}
 
 	return nil
@@ -176,5 +569,12 @@ type cacheIndex struct {
 	Filename       string   `json:"file"`
 	RequiresDist   []string `json:"requires_dist"`
 	RequiresPython string   `json:"requires_python"`
-	// Sum            string   `json:"sum"` // TODO
+	// Sum is the hex encoded sha256 digest of the file.
+	Sum string `json:"sum"`
+	// Index is the URL of the package repository the file was downloaded from.
+	Index string `json:"index,omitempty"`
+	// URL is the location the file was downloaded from.
+	URL string `json:"url,omitempty"`
+	// Source is the source distribution the file was built from.
+	Source *LockedArtifact `json:"source,omitempty"`
 }
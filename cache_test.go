@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/AlexanderEkdahl/rope/version"
+)
+
+func TestCacheEntries(t *testing.T) {
+	c := &Cache{Path: t.TempDir()}
+
+	for _, filename := range []string{"b-1.0-py3-none-any.whl", "a-1.0-py3-none-any.whl", "a-2.0-py3-none-any.whl"} {
+		whl, err := ParseWheelFilename(filename)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		whl.RequiresDist = []string{"six"}
+
+		path := filepath.Join(t.TempDir(), filename)
+		if err := ioutil.WriteFile(path, []byte(filename), 0666); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := c.AddWheel(whl, path); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	entries, err := c.Entries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got: %d", len(entries))
+	}
+	if entries[0].Filename != "a-1.0-py3-none-any.whl" || entries[2].Name != "b" {
+		t.Fatalf("entries not sorted: %v", entries)
+	}
+	if entries[0].Size != int64(len(entries[0].Filename)) || len(entries[0].RequiresDist) != 1 {
+		t.Fatalf("unexpected entry: %+v", entries[0])
+	}
+
+	if err := c.Remove(entries[:1]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries, err = c.Entries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Filename != "a-2.0-py3-none-any.whl" {
+		t.Fatalf("unexpected entries after removal: %v", entries)
+	}
+}
+
+// TestCacheWriterProcess is not a real test. It is invoked as a separate
+// process by TestCacheConcurrentWriters.
+func TestCacheWriterProcess(t *testing.T) {
+	path := os.Getenv("ROPE_TEST_CACHE_WRITER")
+	if path == "" {
+		t.Skip("only run as a subprocess")
+	}
+	writer, _ := strconv.Atoi(os.Getenv("ROPE_TEST_CACHE_WRITER_ID"))
+
+	c := &Cache{Path: path}
+	for i := 0; i < 20; i++ {
+		// Writers add overlapping sets of wheels.
+		filename := fmt.Sprintf("example-%d.0-py3-none-any.whl", (writer+i)%25)
+		whl, err := ParseWheelFilename(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tmp, err := ioutil.TempFile("", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmp.WriteString(filename)
+		tmp.Close()
+
+		if _, err := c.AddWheel(whl, tmp.Name()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestCacheConcurrentWriters(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+	path := t.TempDir()
+
+	const writers = 8
+	cmds := make([]*exec.Cmd, writers)
+	for i := range cmds {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCacheWriterProcess$")
+		cmd.Env = append(os.Environ(),
+			"ROPE_TEST_CACHE_WRITER="+path,
+			fmt.Sprintf("ROPE_TEST_CACHE_WRITER_ID=%d", i),
+		)
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		cmds[i] = cmd
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("writer failed: %v", err)
+		}
+	}
+
+	c := &Cache{Path: path}
+	entries, err := c.Entries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Together the writers add every one of the 25 versions.
+	if len(entries) != 25 {
+		t.Fatalf("unexpected number of entries, got: %d, want: 25", len(entries))
+	}
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if seen[e.Filename] {
+			t.Fatalf("duplicate entry: %s", e.Filename)
+		}
+		seen[e.Filename] = true
+
+		b, err := ioutil.ReadFile(e.Path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(b) != e.Filename {
+			t.Fatalf("unexpected contents of %s: %s", e.Filename, b)
+		}
+	}
+}
+
+func TestCacheEvictsCorruptedWheels(t *testing.T) {
+	oldEnv := env
+	defer func() {
+		env = oldEnv
+	}()
+	env = &Environment{tags: map[string]int{"py3-none-any": 1}}
+	env.init.Do(func() {})
+
+	c := &Cache{Path: t.TempDir()}
+	const index = "https://example.com/simple"
+
+	whl, err := ParseWheelFilename("example-1.0-py3-none-any.whl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	whl.Index = index
+	path := filepath.Join(t.TempDir(), whl.filename)
+	if err := ioutil.WriteFile(path, []byte("wheel"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	cachedPath, err := c.AddWheel(whl, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Wheels are only found in the cache of the index they were downloaded from.
+	if cached, err := c.GetWheel("https://other.example.com/simple", "example", version.MustParse("1.0")); err != nil || cached != nil {
+		t.Fatalf("expected wheel to not be found for other index, got: %v (%v)", cached, err)
+	}
+	cached, err := c.GetWheel(index, "example", version.MustParse("1.0"))
+	if err != nil || cached == nil {
+		t.Fatalf("expected wheel to be found, got: %v (%v)", cached, err)
+	}
+	if cached.Index != index || cached.Hashes["sha256"] == "" {
+		t.Fatalf("unexpected cached wheel: %+v", cached)
+	}
+
+	if err := ioutil.WriteFile(cachedPath, []byte("corrupted"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	// Wheels are only verified once per process.
+	if cached, err := c.GetWheel(index, "example", version.MustParse("1.0")); err != nil || cached == nil {
+		t.Fatalf("expected verified wheel to be found, got: %v (%v)", cached, err)
+	}
+	c = &Cache{Path: c.Path}
+	if cached, err := c.GetWheel(index, "example", version.MustParse("1.0")); err != nil || cached != nil {
+		t.Fatalf("expected corrupted wheel to not be returned, got: %v (%v)", cached, err)
+	}
+
+	entries, err := c.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected corrupted wheel to be evicted, got: %v", entries)
+	}
+	if _, err := os.Stat(cachedPath); !os.IsNotExist(err) {
+		t.Fatalf("expected corrupted file to be removed")
+	}
+}
+
+func TestCacheRemoveStale(t *testing.T) {
+	c := &Cache{Path: t.TempDir()}
+	for _, dir := range []string{"0/pypi/example", cacheVersion + "/pypi/example", "http/0", "http/" + cacheVersion, "store/1"} {
+		if err := os.MkdirAll(filepath.Join(c.Path, dir), 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := c.RemoveStale()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(removed) != 2 || removed[0] != filepath.Join(c.Path, "0") || removed[1] != filepath.Join(c.Path, "http", "0") {
+		t.Fatalf("unexpected removed directories: %v", removed)
+	}
+	for _, dir := range []string{cacheVersion, "http/" + cacheVersion, "store/1"} {
+		if _, err := os.Stat(filepath.Join(c.Path, dir)); err != nil {
+			t.Fatalf("expected %s to be kept: %v", dir, err)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const cacheHelp = `Usage:
+
+  rope cache <command> [options]
+
+The commands are:
+
+  list         list cached wheels
+  info         show cache location and usage, or details of a package
+  verify       verify the integrity of every cached wheel
+  prune        remove cached wheels by age or total size
+  clean        remove everything from the cache
+`
+
+// cacheCommand implements the 'rope cache' subcommands. args starts with
+// the subcommand.
+func cacheCommand(args []string) (int, error) {
+	arg := ""
+	if len(args) > 0 {
+		arg = args[0]
+	}
+
+	switch arg {
+	case "", "help", "--help", "-h":
+		fmt.Printf(cacheHelp)
+		return 2, nil
+	case "list":
+		entries, err := cache.Entries()
+		if err != nil {
+			return 1, err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", e.Filename, formatSize(e.Size), strings.Join(e.RequiresDist, ", "))
+		}
+		return 0, w.Flush()
+	case "info":
+		entries, err := cache.Entries()
+		if err != nil {
+			return 1, err
+		}
+
+		if len(args) > 1 {
+			name := NormalizePackageName(args[1])
+			found := false
+			for _, e := range entries {
+				if e.Name != name {
+					continue
+				}
+				found = true
+
+				fmt.Printf("%s\n", e.Filename)
+				fmt.Printf("  path:            %s\n", e.Path)
+				fmt.Printf("  index:           %s\n", e.Index)
+				fmt.Printf("  sha256:          %s\n", e.Sum)
+				fmt.Printf("  size:            %s\n", formatSize(e.Size))
+				fmt.Printf("  last used:       %s\n", e.ModTime.Format(time.RFC3339))
+				fmt.Printf("  requires_python: %s\n", e.RequiresPython)
+				fmt.Printf("  requires_dist:\n")
+				for _, r := range e.RequiresDist {
+					fmt.Printf("    %s\n", r)
+				}
+			}
+			if !found {
+				return 1, fmt.Errorf("'%s' not found in cache", name)
+			}
+			return 0, nil
+		}
+
+		packages := make(map[string]bool)
+		var size int64
+		for _, e := range entries {
+			packages[e.Name] = true
+			if e.Size > 0 {
+				size += e.Size
+			}
+		}
+		fmt.Printf("location: %s\n", cache.Path)
+		fmt.Printf("packages: %d\n", len(packages))
+		fmt.Printf("wheels:   %d\n", len(entries))
+		fmt.Printf("size:     %s\n", formatSize(size))
+		return 0, nil
+	case "verify":
+		entries, err := cache.Entries()
+		if err != nil {
+			return 1, err
+		}
+
+		failed := 0
+		for _, e := range entries {
+			if err := e.Verify(); err != nil {
+				fmt.Printf("%s: %v\n", e.Filename, err)
+				failed++
+			}
+		}
+		if failed > 0 {
+			return 1, fmt.Errorf("%d of %d cached wheels failed verification", failed, len(entries))
+		}
+		fmt.Printf("%d cached wheels verified\n", len(entries))
+		return 0, nil
+	case "prune":
+		flagSet := pflag.NewFlagSet("prune", pflag.ContinueOnError)
+		olderThan := flagSet.Duration("older-than", 0, "Remove wheels not used within the duration")
+		maxSize := flagSet.String("max-size", "", "Remove the least recently used wheels until the cache is smaller than size, e.g. 10GB")
+		if err := flagSet.Parse(args); err == pflag.ErrHelp {
+			return 0, nil
+		} else if err != nil {
+			return 2, err
+		}
+		if *olderThan == 0 && *maxSize == "" {
+			fmt.Println("rope cache prune: --older-than or --max-size must be provided")
+			return 2, nil
+		}
+
+		var limit int64 = -1
+		if *maxSize != "" {
+			var err error
+			limit, err = parseSize(*maxSize)
+			if err != nil {
+				return 2, err
+			}
+		}
+
+		entries, err := cache.Entries()
+		if err != nil {
+			return 1, err
+		}
+
+		prune := selectPrunable(entries, time.Now().Add(-*olderThan), *olderThan > 0, limit)
+		if err := cache.Remove(prune); err != nil {
+			return 1, err
+		}
+		stale, err := cache.RemoveStale()
+		if err != nil {
+			return 1, err
+		}
+		for _, path := range stale {
+			fmt.Printf("removed %s\n", path)
+		}
+
+		var freed int64
+		for _, e := range prune {
+			if e.Size > 0 {
+				freed += e.Size
+			}
+		}
+		fmt.Printf("removed %d cached wheels (%s)\n", len(prune), formatSize(freed))
+		return 0, nil
+	case "clean":
+		if err := cache.Clean(); err != nil {
+			return 1, err
+		}
+		return 0, nil
+	default:
+		fmt.Printf("rope cache %s: unknown command\n", arg)
+		return 2, nil
+	}
+}
+
+// selectPrunable returns the entries that should be removed from the cache.
+// If useCutoff is true every entry last used before cutoff is selected. If
+// maxSize is not negative the least recently used entries are selected until
+// the remaining entries fit within maxSize bytes. Entries with missing files
+// are always selected.
+func selectPrunable(entries []CacheEntry, cutoff time.Time, useCutoff bool, maxSize int64) []CacheEntry {
+	sorted := append([]CacheEntry{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ModTime.Before(sorted[j].ModTime)
+	})
+
+	var total int64
+	for _, e := range sorted {
+		if e.Size > 0 {
+			total += e.Size
+		}
+	}
+
+	var prune []CacheEntry
+	for _, e := range sorted {
+		switch {
+		case e.Size < 0:
+		case useCutoff && e.ModTime.Before(cutoff):
+		case maxSize >= 0 && total > maxSize:
+		default:
+			continue
+		}
+
+		prune = append(prune, e)
+		if e.Size > 0 {
+			total -= e.Size
+		}
+	}
+
+	return prune
+}
+
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KIB", 1 << 10},
+	{"MIB", 1 << 20},
+	{"GIB", 1 << 30},
+	{"TIB", 1 << 40},
+	{"KB", 1e3},
+	{"MB", 1e6},
+	{"GB", 1e9},
+	{"TB", 1e12},
+	{"K", 1 << 10},
+	{"M", 1 << 20},
+	{"G", 1 << 30},
+	{"T", 1 << 40},
+	{"B", 1},
+}
+
+// parseSize parses a human readable size such as 500MB or 2GiB into bytes.
+func parseSize(s string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: '%s'", s)
+	}
+
+	return int64(n * float64(multiplier)), nil
+}
+
+// formatSize formats bytes as a human readable size.
+func formatSize(n int64) string {
+	if n < 0 {
+		return "missing"
+	}
+
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
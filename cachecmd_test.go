@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelectPrunable(t *testing.T) {
+	now := time.Now()
+	entries := []CacheEntry{
+		{Filename: "new", Size: 100, ModTime: now},
+		{Filename: "old", Size: 100, ModTime: now.Add(-48 * time.Hour)},
+		{Filename: "older", Size: 100, ModTime: now.Add(-72 * time.Hour)},
+		{Filename: "missing", Size: -1},
+	}
+
+	prune := selectPrunable(entries, now.Add(-60*time.Hour), true, -1)
+	if len(prune) != 2 || prune[0].Filename != "missing" || prune[1].Filename != "older" {
+		t.Fatalf("unexpected entries selected by age: %v", prune)
+	}
+
+	prune = selectPrunable(entries, time.Time{}, false, 150)
+	if len(prune) != 3 || prune[2].Filename != "old" {
+		t.Fatalf("unexpected entries selected by size: %v", prune)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	testCases := map[string]int64{
+		"100":    100,
+		"10KB":   10000,
+		"1.5GiB": 3 << 29,
+		"2g":     2 << 30,
+		"5 MB":   5000000,
+	}
+	for input, expected := range testCases {
+		if size, err := parseSize(input); err != nil {
+			t.Fatalf("unexpected error for '%s': %v", input, err)
+		} else if size != expected {
+			t.Fatalf("wrong size for '%s', got: %d, want: %d", input, size, expected)
+		}
+	}
+
+	if _, err := parseSize("ten"); err == nil {
+		t.Fatalf("expected error for invalid size")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ErrConflict is returned in strict mode when the build list does not satisfy
+// every requirement in the dependency graph.
+var ErrConflict = errors.New("conflicting requirements")
+
+// Conflict is a requirement in the dependency graph that is not satisfied by
+// the version in the build list. Minimal version selection only follows lower
+// bounds, e.g. a package requiring 'numpy<1.20' conflicts with numpy 1.21
+// selected due to another package requiring 'numpy>=1.21'.
+type Conflict struct {
+	// Dependant is the canonical name of the package declaring the
+	// requirement.
+	Dependant  string
+	Dependency Dependency
+	// Selected is the version of the dependency in the build list.
+	Selected Dependency
+}
+
+// Conflicts returns the conflicts recorded in the build list by minimal
+// version selection. The result is sorted by the name of the dependency and
+// then by the name of the dependant.
+func Conflicts(list []Dependency) []Conflict {
+	var conflicts []Conflict
+	for _, d := range list {
+		conflicts = append(conflicts, d.Conflicts...)
+	}
+	sortConflicts(conflicts)
+	return conflicts
+}
+
+func sortConflicts(conflicts []Conflict) {
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Dependency.Name != conflicts[j].Dependency.Name {
+			return conflicts[i].Dependency.Name < conflicts[j].Dependency.Name
+		}
+		return conflicts[i].Dependant < conflicts[j].Dependant
+	})
+}
+
+// writeConflicts writes every conflict along with the paths leading to the
+// violated requirement and the requirements that forced the selected version.
+//
+// 	'numpy<1.20' is not satisfied by numpy 1.21.0:
+// 	a 1.0 (rope.json)
+// 	└── numpy 1.21.0 ('numpy<1.20')
+//
+// 	numpy 1.21.0 selected due to:
+// 	  b 1.0 requires 'numpy>=1.21'
+func writeConflicts(output io.Writer, g *Graph, conflicts []Conflict) {
+	// Paths are only enumerated for the dependants involved in a conflict
+	// and only once per dependant.
+	paths := make(map[string][][]edge)
+	truncated := make(map[string]bool)
+
+	for i, c := range conflicts {
+		if i > 0 {
+			fmt.Fprintln(output)
+		}
+
+		fmt.Fprintf(output, "%s is not satisfied by %s %s:\n", requirementLabel(c.Dependency), c.Selected.Name, c.Selected.Version.Canonical())
+		if _, ok := paths[c.Dependant]; !ok {
+			paths[c.Dependant], truncated[c.Dependant] = g.Paths(c.Dependant)
+		}
+		for _, path := range paths[c.Dependant] {
+			writePath(output, g, append(path, edge{dependant: c.Dependant, dependency: c.Dependency}))
+		}
+		if truncated[c.Dependant] {
+			fmt.Fprintf(output, "(only the first %d paths are shown)\n", maxPaths)
+		}
+
+		fmt.Fprintln(output)
+		writeSelected(output, c.Selected)
+		if c.Selected.Specifiers.Empty() {
+			fmt.Fprintf(output, "  (no version of '%s' satisfies every requirement)\n", c.Selected.Name)
+		}
+	}
+}
+
+// checkConflicts writes the conflicts of the build list to output. If strict
+// is true ErrConflict is returned if any conflict is found.
+func checkConflicts(ctx context.Context, output io.Writer, base, list []Dependency, index PackageIndex, strict bool) error {
+	// The dependency graph is only needed to show the paths leading to the
+	// conflicting requirements.
+	conflicts := Conflicts(list)
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	graph, err := NewGraph(ctx, base, list, index)
+	if err != nil {
+		return err
+	}
+
+	writeConflicts(output, graph, conflicts)
+	if strict {
+		return fmt.Errorf("%d %w", len(conflicts), ErrConflict)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/AlexanderEkdahl/rope/version"
+)
+
+func TestConflicts(t *testing.T) {
+	specifiers := func(s string) version.SpecifierSet {
+		set, err := version.ParseSpecifierSet(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return set
+	}
+	dependency := func(name, requirement string) Dependency {
+		return Dependency{
+			Name:        name,
+			Version:     version.Minimal(specifiers(requirement)),
+			Requirement: name + requirement,
+			Specifiers:  specifiers(requirement),
+		}
+	}
+	index := &testPackageIndex{
+		map[string][]testPackage{
+			"a": {{name: "a", version: version.MustParse("1.0"), dependencies: []Dependency{dependency("numpy", "<1.20")}}},
+			"b": {{name: "b", version: version.MustParse("1.0"), dependencies: []Dependency{dependency("numpy", ">=1.21")}}},
+			"numpy": {
+				{name: "numpy", version: version.MustParse("1.19.0")},
+				{name: "numpy", version: version.MustParse("1.21.0")},
+			},
+		},
+	}
+	base := []Dependency{
+		{Name: "a", Version: version.MustParse("1.0")},
+		{Name: "b", Version: version.MustParse("1.0")},
+	}
+
+	// Minimal version selection follows the lower bound and records the
+	// requirement it violates.
+	list, _, err := MinimalVersionSelection(context.Background(), base, index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conflicts := Conflicts(list)
+	if len(conflicts) != 1 || conflicts[0].Dependant != "a" || conflicts[0].Selected.Name != "numpy" {
+		t.Fatalf("got: %v, want: a requiring numpy", conflicts)
+	}
+
+	// Conflicts are only reported unless strict.
+	var sb strings.Builder
+	if err := checkConflicts(context.Background(), &sb, base, list, index, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `'numpy<1.20' is not satisfied by numpy 1.21.0:
+a 1.0 (rope.json)
+└── numpy 1.21.0 ('numpy<1.20')
+
+numpy 1.21.0 selected due to:
+  b 1.0 requires 'numpy>=1.21'
+  (no version of 'numpy' satisfies every requirement)
+`
+	if sb.String() != expected {
+		t.Fatalf("unexpected output, got:\n%s\nwant:\n%s", sb.String(), expected)
+	}
+
+	sb.Reset()
+	if err := checkConflicts(context.Background(), &sb, base, list, index, true); !errors.Is(err, ErrConflict) {
+		t.Fatalf("got: %v, want: %v", err, ErrConflict)
+	}
+
+	// The dependency graph is not constructed without conflicts.
+	list, _, err = MinimalVersionSelection(context.Background(), base[:1], index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sb.Reset()
+	if err := checkConflicts(context.Background(), &sb, base[:1], list, &testPackageIndex{}, true); err != nil || sb.Len() > 0 {
+		t.Fatalf("got: %v, %q, want: no conflicts", err, sb.String())
+	}
+}
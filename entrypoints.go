@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// entryPoint is an entry point exposed by an installed distribution.
+// https://packaging.python.org/specifications/entry-points/
+type entryPoint struct {
+	Group  string
+	Name   string
+	Module string
+	// Attr is the dotted path to the object within the module. Empty if the
+	// entry point refers to the module itself.
+	Attr string
+}
+
+// parseEntryPoints parses the entry points of the console_scripts and
+// gui_scripts groups in entry_points.txt.
+func parseEntryPoints(r io.Reader) ([]entryPoint, error) {
+	var entryPoints []entryPoint
+
+	group := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		if group != "console_scripts" && group != "gui_scripts" {
+			continue
+		}
+
+		sep := strings.Index(line, "=")
+		if sep < 0 {
+			return nil, fmt.Errorf("invalid entry point: '%s'", line)
+		}
+		name := strings.TrimSpace(line[:sep])
+		object := strings.TrimSpace(line[sep+1:])
+		// Extras are deprecated and ignored.
+		if i := strings.Index(object, "["); i >= 0 {
+			object = strings.TrimSpace(object[:i])
+		}
+
+		ep := entryPoint{Group: group, Name: name, Module: object}
+		if i := strings.Index(object, ":"); i >= 0 {
+			ep.Module = strings.TrimSpace(object[:i])
+			ep.Attr = strings.TrimSpace(object[i+1:])
+		}
+		if name == "" || ep.Module == "" || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("invalid entry point: '%s'", line)
+		}
+
+		entryPoints = append(entryPoints, ep)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entryPoints, nil
+}
+
+// launcher returns a Python script invoking the entry point.
+func (ep entryPoint) launcher(interpreter string) []byte {
+	importStatement := fmt.Sprintf("import %s", ep.Module)
+	call := ep.Module
+	if ep.Attr != "" {
+		first := strings.SplitN(ep.Attr, ".", 2)[0]
+		importStatement = fmt.Sprintf("from %s import %s", ep.Module, first)
+		call = ep.Attr
+	}
+
+	return []byte(fmt.Sprintf(`#!%s
+# -*- coding: utf-8 -*-
+import re
+import sys
+%s
+if __name__ == '__main__':
+    sys.argv[0] = re.sub(r'(-script\.pyw|\.exe)?$', '', sys.argv[0])
+    sys.exit(%s())
+`, interpreter, importStatement, call))
+}
+
+// installLaunchers generates a launcher in the scripts directory for every
+// console and GUI script declared in the entry_points.txt file of the
+// installed distribution. Existing scripts are not replaced.
+func installLaunchers(distInfo string, scheme installScheme, interpreter func() (string, error)) ([]installedFile, error) {
+	f, err := os.Open(filepath.Join(distInfo, "entry_points.txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entryPoints, err := parseEntryPoints(f)
+	if err != nil {
+		return nil, fmt.Errorf("parsing entry_points.txt: %w", err)
+	}
+
+	var installed []installedFile
+	for _, ep := range entryPoints {
+		target := filepath.Join(scheme.Scripts, ep.Name)
+		if _, err := os.Lstat(target); err == nil {
+			continue
+		}
+
+		python, err := interpreter()
+		if err != nil {
+			return nil, err
+		}
+
+		contents := ep.launcher(python)
+		if err := writeFile(target, contents, 0555); err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(contents)
+		installed = append(installed, installedFile{path: target, digest: sum[:], size: int64(len(contents))})
+	}
+
+	return installed, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEntryPoints(t *testing.T) {
+	entryPoints, err := parseEntryPoints(strings.NewReader(`
+[console_scripts]
+black = black:patched_main
+blackd = blackd:patched_main [d]
+example-module=example
+
+[gui_scripts]
+example-gui = example.gui:App.run
+
+[pytest11]
+example = example.plugin
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []entryPoint{
+		{Group: "console_scripts", Name: "black", Module: "black", Attr: "patched_main"},
+		{Group: "console_scripts", Name: "blackd", Module: "blackd", Attr: "patched_main"},
+		{Group: "console_scripts", Name: "example-module", Module: "example"},
+		{Group: "gui_scripts", Name: "example-gui", Module: "example.gui", Attr: "App.run"},
+	}
+	if !reflect.DeepEqual(entryPoints, expected) {
+		t.Fatalf("unexpected entry points, got: %+v, want: %+v", entryPoints, expected)
+	}
+
+	if _, err := parseEntryPoints(strings.NewReader("[console_scripts]\n../evil = example:main\n")); err == nil {
+		t.Fatalf("expected error for invalid name")
+	}
+}
+
+func TestEntryPointLauncher(t *testing.T) {
+	launcher := string(entryPoint{Name: "example-gui", Module: "example.gui", Attr: "App.run"}.launcher("/usr/bin/python3"))
+
+	for _, expected := range []string{
+		"#!/usr/bin/python3\n",
+		"from example.gui import App\n",
+		"sys.exit(App.run())\n",
+	} {
+		if !strings.Contains(launcher, expected) {
+			t.Fatalf("expected launcher to contain %q, got: %s", expected, launcher)
+		}
+	}
+}
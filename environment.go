@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -791,6 +792,16 @@ func (e *Environment) Priority(triple string) (int, error) {
 	return v, nil
 }
 
+// Interpreter returns the absolute path to the Python interpreter.
+func (e *Environment) Interpreter() (string, error) {
+	path, err := exec.LookPath("python")
+	if err != nil {
+		return "", fmt.Errorf("finding python interpreter: %w", err)
+	}
+
+	return filepath.Abs(path)
+}
+
 func (e *Environment) SatisfiesPythonVersion(specifier string) (bool, error) {
 	if specifier == "" {
 		return true, nil
@@ -869,7 +880,7 @@ type EnvironmentExtra struct {
 
 func (e *EnvironmentExtra) Get(k string) (string, error) {
 	if k == "extra" {
-		return e.extra, nil
+		return version.NormalizeExtra(e.extra), nil
 	}
 
 	return e.env.Get(k)
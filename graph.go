@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Graph is the dependency graph induced by a build list. Every node is a
+// package selected by minimal version selection and every edge is a
+// dependency declared by the selected version of that package.
+type Graph struct {
+	// Roots are the direct dependencies of the project.
+	Roots []Dependency
+	// Nodes holds the selected version of every package in the build list
+	// indexed by canonical name.
+	Nodes map[string]Dependency
+	// Edges holds the dependencies declared by the selected version of
+	// every package in the build list indexed by canonical name.
+	Edges map[string][]Dependency
+}
+
+// NewGraph constructs the dependency graph for the build list produced by
+// minimal version selection from the direct dependencies base.
+func NewGraph(ctx context.Context, base, list []Dependency, index PackageIndex) (*Graph, error) {
+	g := &Graph{
+		Roots: base,
+		Nodes: make(map[string]Dependency, len(list)),
+		Edges: make(map[string][]Dependency, len(list)),
+	}
+
+	for _, d := range list {
+		p, err := index.FindPackage(ctx, d.Name, d.Version)
+		if err != nil {
+			return nil, fmt.Errorf("finding package '%s-%s': %w", d.Name, d.Version, err)
+		}
+
+		g.Nodes[d.Name] = d
+		g.Edges[d.Name] = p.Dependencies(d.Extras)
+	}
+
+	return g, nil
+}
+
+// Dependants returns the packages in the graph that directly depends on the
+// package identified by name. The result is sorted by name.
+func (g *Graph) Dependants(name string) []Dependency {
+	var dependants []Dependency
+	for parent, edges := range g.Edges {
+		for _, d := range edges {
+			if d.Name == name {
+				dependants = append(dependants, g.Nodes[parent])
+				break
+			}
+		}
+	}
+
+	sort.Slice(dependants, func(i, j int) bool {
+		return dependants[i].Name < dependants[j].Name
+	})
+	return dependants
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+/*
+
+Metadata served by package indexes(PyPI JSON responses, simple repository
+pages and core metadata files) is cached to allow resolving dependencies
+without network access.
+
+<cache> / http / <cacheVersion> / <key>
+
+<key> is derived from the URL and the Accept header of the request. Every
+file holds a single line of JSON describing the response followed by the
+body. Cached responses are revalidated using If-None-Match and
+If-Modified-Since unless rope is offline, in which case cached responses are
+used as is and every other request fails.
+
+*/
+
+// ErrOffline is returned for requests that can not be served from the cache
+// when rope is offline.
+var ErrOffline = errors.New("not available offline")
+
+type metadataKey struct{}
+
+// newMetadataRequest returns a GET request for url whose response is cached
+// by httpCache.
+func newMetadataRequest(ctx context.Context, url string) (*http.Request, error) {
+	return http.NewRequestWithContext(context.WithValue(ctx, metadataKey{}, true), http.MethodGet, url, nil)
+}
+
+// httpCache is a http.RoundTripper caching the responses of requests created
+// using newMetadataRequest.
+type httpCache struct {
+	Base  http.RoundTripper
+	Cache *Cache
+	// Offline disables every request that can not be served from the cache.
+	Offline bool
+}
+
+// cachedResponse is the header of a cached response.
+type cachedResponse struct {
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header"`
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *httpCache) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Context().Value(metadataKey{}) == nil || r.Method != http.MethodGet {
+		if t.Offline {
+			return nil, ErrOffline
+		}
+		return t.Base.RoundTrip(r)
+	}
+
+	path, err := t.path(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, body, err := readCachedResponse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Offline {
+		if cached == nil {
+			return nil, ErrOffline
+		}
+		return cached.response(r, body), nil
+	}
+
+	if cached != nil {
+		etag, lastModified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			// RoundTrip must not modify the provided request.
+			r = r.Clone(r.Context())
+			if etag != "" {
+				r.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				r.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+
+	res, err := t.Base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotModified && cached != nil {
+		res.Body.Close()
+		return cached.response(r, body), nil
+	}
+
+	if !cacheableStatus(res.StatusCode) {
+		return res, nil
+	}
+
+	body, err = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	cr := &cachedResponse{URL: r.URL.String(), StatusCode: res.StatusCode, Header: res.Header}
+	if err := writeCachedResponse(path, cr, body); err != nil {
+		// Failing to cache a response does not prevent it from being used.
+		fmt.Fprintf(os.Stderr, "caching %s: %v\n", r.URL, err)
+	}
+
+	return res, nil
+}
+
+// path returns the path of the cached response to the request r.
+func (t *httpCache) path(r *http.Request) (string, error) {
+	t.Cache.once.Do(t.Cache.setup)
+	if t.Cache.err != nil {
+		return "", t.Cache.err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", r.URL, r.Header.Get("Accept"))
+	return filepath.Join(t.Cache.Path, "http", cacheVersion, hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// cacheableStatus returns true if responses with the status code are
+// cached. Redirects and missing packages are cached to allow them to be
+// followed when offline.
+func cacheableStatus(code int) bool {
+	switch code {
+	case http.StatusOK,
+		http.StatusNotFound,
+		http.StatusMovedPermanently,
+		http.StatusFound,
+		http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+func (cr *cachedResponse) response(r *http.Request, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", cr.StatusCode, http.StatusText(cr.StatusCode)),
+		StatusCode:    cr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cr.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
+
+// readCachedResponse reads the response cached at path. A nil response is
+// returned if nothing has been cached. Unreadable entries are ignored.
+func readCachedResponse(path string) (*cachedResponse, []byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("reading cached response: %w", err)
+	}
+
+	n := bytes.IndexByte(b, '\n')
+	if n < 0 {
+		return nil, nil, nil
+	}
+
+	var cr cachedResponse
+	if err := json.Unmarshal(b[:n], &cr); err != nil {
+		return nil, nil, nil
+	}
+
+	return &cr, b[n+1:], nil
+}
+
+func writeCachedResponse(path string, cr *cachedResponse, body []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+
+	header, err := json.Marshal(cr)
+	if err != nil {
+		return err
+	}
+
+	// Responses are replaced atomically as they are read without a lock.
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	for _, b := range [][]byte{header, []byte("\n"), body} {
+		if _, err := tmp.Write(b); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
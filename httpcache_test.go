@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AlexanderEkdahl/rope/version"
+)
+
+func TestHTTPCacheRevalidation(t *testing.T) {
+	requests, revalidated := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidated++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "metadata")
+	}))
+	defer server.Close()
+
+	c := &Cache{Temporary: true}
+	defer c.Close()
+	client := &http.Client{Transport: &httpCache{Base: http.DefaultTransport, Cache: c}}
+
+	get := func(ctx context.Context, metadata bool) (string, error) {
+		r, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		if metadata {
+			r, err = newMetadataRequest(ctx, server.URL)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		res, err := client.Do(r)
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+
+		b, err := ioutil.ReadAll(res.Body)
+		return string(b), err
+	}
+
+	for i := 0; i < 2; i++ {
+		body, err := get(context.Background(), true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if body != "metadata" {
+			t.Fatalf("got: %s, want: metadata", body)
+		}
+	}
+	if requests != 2 || revalidated != 1 {
+		t.Fatalf("got: %d requests(%d revalidated), want: 2 requests(1 revalidated)", requests, revalidated)
+	}
+
+	// Other requests are not cached.
+	if _, err := get(context.Background(), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 3 || revalidated != 1 {
+		t.Fatalf("got: %d requests(%d revalidated), want: 3 requests(1 revalidated)", requests, revalidated)
+	}
+
+	client.Transport.(*httpCache).Offline = true
+	if body, err := get(context.Background(), true); err != nil || body != "metadata" {
+		t.Fatalf("got: %s, %v, want: metadata", body, err)
+	}
+	if _, err := get(context.Background(), false); !errors.Is(err, ErrOffline) {
+		t.Fatalf("got: %v, want: %v", err, ErrOffline)
+	}
+	if requests != 3 {
+		t.Fatalf("got: %d requests, want: 3", requests)
+	}
+}
+
+func TestFindPackageOffline(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/simple/example/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", simpleJSONMediaType)
+		fmt.Fprint(w, `{"meta": {"api-version": "1.0"}, "files": [
+			{"filename": "example-1.0.tar.gz", "url": "example-1.0.tar.gz", "hashes": {"sha256": "abc"}}
+		]}`)
+	})
+	mux.HandleFunc("/pypi/example/1.0/json", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/pypi/example/json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"releases": {"0.9": [{"filename": "example-0.9.tar.gz"}], "1.1": [{"filename": "example-1.1.tar.gz"}]}}`)
+	})
+	mux.HandleFunc("/pypi/example/1.1/json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"urls": [{"filename": "example-1.1.tar.gz", "packagetype": "sdist", "url": "https://example.com/example-1.1.tar.gz"}]}`)
+	})
+	server := httptest.NewServer(mux)
+
+	oldCache, oldClient := cache, client
+	defer func() {
+		cache, client = oldCache, oldClient
+	}()
+	cache = &Cache{Temporary: true}
+	defer cache.Close()
+
+	indexes := []struct {
+		index   PackageIndex
+		version string
+		want    string
+	}{
+		{&Index{url: server.URL + "/simple"}, "", "1.0"},
+		// The version is relaxed using the releases of the package.
+		{&PyPI{url: server.URL}, "1.0", "1.1"},
+	}
+
+	find := func(offline bool) {
+		client = &http.Client{Transport: &httpCache{Base: http.DefaultTransport, Cache: cache, Offline: offline}}
+		for _, test := range indexes {
+			v, _ := version.Parse(test.version)
+			p, err := test.index.FindPackage(context.Background(), "example", v)
+			if err != nil {
+				t.Fatalf("offline: %v, %T: unexpected error: %v", offline, test.index, err)
+			}
+			if got := p.Version().String(); got != test.want {
+				t.Fatalf("offline: %v, %T: got: %s, want: %s", offline, test.index, got, test.want)
+			}
+		}
+	}
+
+	find(false)
+	server.Close()
+	find(true)
+
+	_, err := (&Index{url: server.URL + "/simple"}).FindPackage(context.Background(), "missing", version.Version{})
+	if !errors.Is(err, ErrOffline) {
+		t.Fatalf("got: %v, want: %v", err, ErrOffline)
+	}
+}
@@ -2,14 +2,11 @@ package main
 
 import (
 	"context"
-	"encoding/xml"
-	"errors"
 	"fmt"
-	"io"
+	"mime"
 	"net/http"
-	"net/url"
 	"os"
-	"path"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -26,87 +23,34 @@ const (
 // such as the Python Package Index.
 // The "API" is defined at: https://www.python.org/dev/peps/pep-0503/
 type Index struct {
-	url string
+	url         string
+	prereleases *Prereleases
 }
 
 func (i *Index) FindPackage(ctx context.Context, name string, v version.Version) (Package, error) {
 	name = NormalizePackageName(name)
 
-	wheel, err := checkCache(ctx, name, v)
+	wheel, err := checkCache(ctx, i.url, name, v)
 	if err != nil {
 		return nil, err
 	} else if wheel != nil {
 		return wheel, nil
 	}
 
-	r, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s/", i.url, name), nil)
+	files, err := i.files(ctx, name)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := http.DefaultClient.Do(r)
+	foundPackage, err := selectPackage(files, v, i.url, i.prereleases.Allowed(name))
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
-
-	switch res.StatusCode {
-	case http.StatusOK:
-		// continue
-	case http.StatusNotFound:
-		return nil, ErrPackageNotFound
-	default:
-		return nil, fmt.Errorf("failed HTTP request: %s", res.Status)
-	}
-
-	var foundPackages []Package
-	var foundVersion version.Version
-	dec := xml.NewDecoder(res.Body)
-	for {
-		token, err := dec.Token()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return nil, err
-		}
-
-		if token, ok := token.(xml.StartElement); ok && token.Name.Local == "a" {
-			href := ""
-			for _, attr := range token.Attr {
-				if attr.Name.Local == "href" {
-					href = attr.Value
-				}
-			}
-
-			p, ok := i.checkCompatability(href)
-			if ok {
-				if v.Unspecified() {
-					if !foundVersion.Unspecified() && p.Version().GreaterThan(foundVersion) {
-						// Reset found packages since a greater version has been found.
-						foundPackages = nil
-					}
-
-					foundPackages = append(foundPackages, p)
-					foundVersion = p.Version()
-				} else if p.Version().Match(v) {
-					foundPackages = append(foundPackages, p)
-				} else if !foundVersion.Unspecified() {
-					// stop early since all packages matching the version v has been found.
-					break
-				}
-			}
-		}
-	}
-
-	if len(foundPackages) == 0 {
-		return nil, fmt.Errorf("compatible package not found")
-	}
-	foundPackage := selectPrefered(foundPackages, env)
 
 	if v.Unspecified() {
 		// If the original query did not specify a version, check the cache to see if
 		// the found package is already present in the cache.
-		wheel, err := checkCache(ctx, name, foundPackage.Version())
+		wheel, err := checkCache(ctx, i.url, name, foundPackage.Version())
 		if err != nil {
 			return nil, err
 		} else if wheel != nil {
@@ -123,31 +67,110 @@ func (i *Index) FindPackage(ctx context.Context, name string, v version.Version)
 	return foundPackage, nil
 }
 
-func (i *Index) checkCompatability(href string) (Package, bool) {
-	url, err := url.Parse(href)
+// Versions returns every version of the package with a distribution
+// compatible with the current environment.
+func (i *Index) Versions(ctx context.Context, name string) ([]version.Version, error) {
+	files, err := i.files(ctx, NormalizePackageName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	// Consistent with selectPackage.
+	if len(files) == 0 {
+		return nil, ErrPackageNotFound
+	}
+
+	return fileVersions(files, i.url), nil
+}
+
+// files returns the files listed on the project page of the package.
+func (i *Index) files(ctx context.Context, name string) ([]simpleFile, error) {
+	pageURL := fmt.Sprintf("%s/%s/", strings.TrimSuffix(i.url, "/"), name)
+	r, err := newMetadataRequest(ctx, pageURL)
 	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Accept", simpleAccept)
+
+	res, err := client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		// continue
+	case http.StatusNotFound:
+		return nil, ErrPackageNotFound
+	default:
+		return nil, fmt.Errorf("failed HTTP request: %s", res.Status)
+	}
+
+	// Servers may redirect, e.g. to the normalized name, and links are relative
+	// to the final location.
+	if mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mediaType == simpleJSONMediaType {
+		return parseSimpleJSON(res.Body, res.Request.URL)
+	}
+	return parseSimpleHTML(res.Body, res.Request.URL)
+}
+
+// fileVersions returns the versions of the distributions among files that
+// are compatible with the current environment. Yanked files are ignored.
+func fileVersions(files []simpleFile, index string) []version.Version {
+	var vs []version.Version
+	seen := make(map[version.Version]bool)
+	for _, f := range files {
+		if f.Yanked {
+			continue
+		}
+		p, ok := packageFromFile(f, index)
+		if !ok || seen[p.Version()] {
+			continue
+		}
+		seen[p.Version()] = true
+		vs = append(vs, p.Version())
+	}
+	return vs
+}
+
+// packageFromFile instantiates the package distributed by the file found in
+// index. The returned bool is false if the file is not a distribution or if
+// the distribution is incompatible with the current environment.
+func packageFromFile(f simpleFile, index string) (Package, bool) {
+	// Invalid specifiers are ignored in the same way as pip.
+	if ok, err := env.SatisfiesPythonVersion(f.RequiresPython); err == nil && !ok {
 		return nil, false
 	}
 
-	filename := path.Base(url.Path)
-	if strings.HasSuffix(filename, ".whl") {
-		whl, err := ParseWheelFilename(filename)
+	if strings.HasSuffix(f.Filename, ".whl") {
+		whl, err := ParseWheelFilename(f.Filename)
 		if err != nil {
 			return nil, false
 		}
-		whl.URL = href
+		whl.URL = f.URL
+		whl.Index = index
+		whl.Hashes = f.Hashes
+		whl.RequiresPython = f.RequiresPython
+		whl.Yanked = f.Yanked
+		whl.HasMetadata = f.Metadata
+		whl.MetadataHashes = f.MetadataHashes
 
 		if !whl.Compatible(env) {
 			return nil, false
 		}
 
 		return whl, true
-	} else if sdistSuffix := sourceDistributionSuffix(filename); sdistSuffix != "" {
-		sdist, err := ParseSdistFilename(filename, sdistSuffix)
+	} else if sdistSuffix := sourceDistributionSuffix(f.Filename); sdistSuffix != "" {
+		sdist, err := ParseSdistFilename(f.Filename, sdistSuffix)
 		if err != nil {
 			return nil, false
 		}
-		sdist.url = href
+		sdist.url = f.URL
+		sdist.index = index
+		sdist.hashes = f.Hashes
+		sdist.requiresPython = f.RequiresPython
+		sdist.yanked = f.Yanked
 
 		return sdist, true
 	} else {
@@ -155,10 +178,69 @@ func (i *Index) checkCompatability(href string) (Package, bool) {
 	}
 }
 
+// selectPackage selects the preferred distribution with version v among the
+// files found in index. If v is unspecified the greatest version is selected. Yanked files
+// are only selected when v is specified and no other file matches(PEP 592).
+func selectPackage(files []simpleFile, v version.Version, index string, allowPrereleases bool) (Package, error) {
+	if len(files) == 0 {
+		return nil, ErrPackageNotFound
+	}
+
+	var foundPackages, yankedPackages, latestPackages []Package
+	for _, f := range files {
+		p, ok := packageFromFile(f, index)
+		if !ok {
+			continue
+		}
+
+		if v.Unspecified() {
+			if !f.Yanked {
+				latestPackages = append(latestPackages, p)
+			}
+		} else if p.Version().Match(v) {
+			if f.Yanked {
+				yankedPackages = append(yankedPackages, p)
+			} else {
+				foundPackages = append(foundPackages, p)
+			}
+		}
+	}
+
+	// The latest version is selected when no version is requested. Final
+	// releases are preferred over pre-releases unless allowed.
+	if v.Unspecified() {
+		vs := make([]version.Version, 0, len(latestPackages))
+		for _, p := range latestPackages {
+			vs = append(vs, p.Version())
+		}
+		sort.Slice(vs, func(i, j int) bool {
+			return version.Compare(vs[i], vs[j]) > 0
+		})
+
+		if latest, ok := selectVersion(vs, allowPrereleases); ok {
+			for _, p := range latestPackages {
+				if version.Compare(p.Version(), latest) == 0 {
+					foundPackages = append(foundPackages, p)
+				}
+			}
+		}
+	}
+
+	if len(foundPackages) == 0 {
+		foundPackages = yankedPackages
+	}
+	if len(foundPackages) == 0 {
+		return nil, ErrCompatiblePackageNotFound
+	}
+
+	return selectPrefered(foundPackages, env), nil
+}
+
 // LinkIndex is a simple form of an index such as:
 // https://download.pytorch.org/whl/torch_stable.html
 type LinkIndex struct {
-	url string
+	url         string
+	prereleases *Prereleases
 }
 
 // FindPackage finds the package with the specified name and optionally version in
@@ -166,19 +248,65 @@ type LinkIndex struct {
 func (i *LinkIndex) FindPackage(ctx context.Context, name string, v version.Version) (Package, error) {
 	name = NormalizePackageName(name)
 
-	wheel, err := checkCache(ctx, name, v)
+	wheel, err := checkCache(ctx, i.url, name, v)
 	if err != nil {
 		return nil, err
 	} else if wheel != nil {
 		return wheel, nil
 	}
 
-	r, err := http.NewRequestWithContext(ctx, http.MethodGet, i.url, nil)
+	packageFiles, err := i.files(ctx, name)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := http.DefaultClient.Do(r)
+	foundPackage, err := selectPackage(packageFiles, v, i.url, i.prereleases.Allowed(name))
+	if err != nil {
+		return nil, err
+	}
+
+	if v.Unspecified() {
+		wheel, err := checkCache(ctx, i.url, name, foundPackage.Version())
+		if err != nil {
+			return nil, err
+		} else if wheel != nil {
+			return wheel, nil
+		}
+	}
+
+	if p, ok := foundPackage.(interface{ extractDependencies(context.Context) error }); ok {
+		if err := p.extractDependencies(ctx); err != nil {
+			return nil, fmt.Errorf("extracting dependencies: %w", err)
+		}
+	}
+
+	return foundPackage, nil
+}
+
+// Versions returns every version of the package with a distribution
+// compatible with the current environment.
+func (i *LinkIndex) Versions(ctx context.Context, name string) ([]version.Version, error) {
+	files, err := i.files(ctx, NormalizePackageName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	// Consistent with selectPackage.
+	if len(files) == 0 {
+		return nil, ErrPackageNotFound
+	}
+
+	return fileVersions(files, i.url), nil
+}
+
+// files returns the files of the package linked from the page.
+func (i *LinkIndex) files(ctx context.Context, name string) ([]simpleFile, error) {
+	r, err := newMetadataRequest(ctx, i.url)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Do(r)
 	if err != nil {
 		return nil, err
 	}
@@ -191,71 +319,40 @@ func (i *LinkIndex) FindPackage(ctx context.Context, name string, v version.Vers
 		return nil, fmt.Errorf("failed HTTP request: %s", res.Status)
 	}
 
-	dec := xml.NewDecoder(res.Body)
-	for {
-		token, err := dec.Token()
-		var syntaxError *xml.SyntaxError
-		if err == io.EOF {
-			break
-		} else if errors.As(err, &syntaxError) && syntaxError.Msg == "unexpected EOF" {
-			// Safe to assume no more links will be found unless index download was
-			// unexpectedly terminated half way through. This is here since pip
-			// seemingly does not care about invalid XML.
-			break
-		} else if err != nil {
-			return nil, err
-		}
-
-		if token, ok := token.(xml.StartElement); ok && token.Name.Local == "a" {
-			href := ""
-			for _, attr := range token.Attr {
-				if attr.Name.Local == "href" {
-					href = attr.Value
-				}
-			}
+	files, err := parseSimpleHTML(res.Body, res.Request.URL)
+	if err != nil {
+		return nil, err
+	}
 
-			url, err := url.Parse(href)
+	// The page links to distributions of every package.
+	var packageFiles []simpleFile
+	for _, f := range files {
+		var fileName string
+		if strings.HasSuffix(f.Filename, ".whl") {
+			whl, err := ParseWheelFilename(f.Filename)
 			if err != nil {
-				return nil, err
-			}
-
-			var p Package
-			filename := path.Base(url.Path)
-			if strings.HasSuffix(filename, ".whl") {
-				whl, err := ParseWheelFilename(filename)
-				if err != nil {
-					return nil, err
-				}
-
-				if !whl.Compatible(env) {
-					continue
-				}
-
-				p = whl
-			} else if suffix := sourceDistributionSuffix(filename); suffix != "" {
-				sdist, err := ParseSdistFilename(filename, suffix)
-				if err != nil {
-					return nil, err
-				}
-
-				p = sdist
+				continue
 			}
-
-			if !p.Version().Equal(v) {
+			fileName = whl.name
+		} else if suffix := sourceDistributionSuffix(f.Filename); suffix != "" {
+			sdist, err := ParseSdistFilename(f.Filename, suffix)
+			if err != nil {
 				continue
 			}
+			fileName = sdist.name
+		}
 
-			// TODO: Support ordering found packages by preference
-			return p, nil
+		if fileName == name {
+			packageFiles = append(packageFiles, f)
 		}
 	}
 
-	return nil, nil
+	return packageFiles, nil
 }
 
-func checkCache(ctx context.Context, name string, v version.Version) (*Wheel, error) {
+func checkCache(ctx context.Context, index, name string, v version.Version) (*Wheel, error) {
 	// TODO: Move this into the cache(and cache dependency list).
-	if wheel, err := cache.GetWheel(name, v); err != nil {
+	if wheel, err := cache.GetWheel(index, name, v); err != nil {
 		return nil, err
 	} else if cacheOnly, _ := strconv.ParseBool(os.Getenv("ROPE_CACHE_ONLY")); cacheOnly && wheel == nil {
 		return nil, fmt.Errorf("package not found in cache (ROPE_CACHE_ONLY is set)")
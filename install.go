@@ -0,0 +1,315 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*
+
+Every wheel is installed into its own prefix following the layout of
+a Python installation. Files in the <name>.data directory of the wheel are
+mapped to the corresponding directory of the scheme. Wheels are installed into
+the shared store(see store.go) and linked into the project.
+https://www.python.org/dev/peps/pep-0427/#installing-a-wheel-distribution-1-0-py32-none-any-whl
+
+./ropedir / <installVersion> / <wheel> / site-packages     purelib and platlib
+                                       / bin               scripts
+                                       / include / <name>  headers
+                                       / data              data
+
+*/
+
+// installVersion is the version of the install layout. If the layout is ever
+// changed in a backward incompatible manner this value will be changed.
+const installVersion = "1"
+
+// installScheme describes where each category of files in a wheel are
+// installed.
+type installScheme struct {
+	Prefix  string
+	Purelib string
+	Platlib string
+	Scripts string
+	Headers string
+	Data    string
+}
+
+func newInstallScheme(prefix, name string) installScheme {
+	sitePackages := filepath.Join(prefix, "site-packages")
+
+	return installScheme{
+		Prefix:  prefix,
+		Purelib: sitePackages,
+		Platlib: sitePackages,
+		Scripts: filepath.Join(prefix, "bin"),
+		Headers: filepath.Join(prefix, "include", name),
+		Data:    filepath.Join(prefix, "data"),
+	}
+}
+
+// scriptsPath returns the scripts directory of the installation with the
+// provided site-packages directory as returned by Wheel.Install.
+func scriptsPath(sitePackages string) string {
+	return filepath.Join(filepath.Dir(sitePackages), "bin")
+}
+
+// target returns the installation path of the wheel member. The returned
+// bool is true if the member is a script.
+func (s installScheme) target(member string) (string, bool, error) {
+	// Validate the full member name as the remainder of .data members is
+	// joined onto the scheme directories.
+	if _, err := safeJoin(s.Prefix, member); err != nil {
+		return "", false, err
+	}
+
+	split := strings.SplitN(member, "/", 3)
+	if len(split) < 3 || !strings.HasSuffix(split[0], ".data") {
+		return filepath.Join(s.Purelib, filepath.FromSlash(member)), false, nil
+	}
+
+	rest := filepath.FromSlash(split[2])
+	switch split[1] {
+	case "purelib":
+		return filepath.Join(s.Purelib, rest), false, nil
+	case "platlib":
+		return filepath.Join(s.Platlib, rest), false, nil
+	case "scripts":
+		return filepath.Join(s.Scripts, rest), true, nil
+	case "headers":
+		return filepath.Join(s.Headers, rest), false, nil
+	case "data":
+		return filepath.Join(s.Data, rest), false, nil
+	default:
+		return "", false, fmt.Errorf("unknown install scheme for '%s': '%s'", member, split[1])
+	}
+}
+
+// installedFile is a file written during installation.
+type installedFile struct {
+	path   string
+	digest []byte
+	size   int64
+}
+
+// extract installs the wheel according to scheme. Every file is verified
+// against the RECORD file of the wheel. Launchers are generated for every
+// entry point script and the RECORD file is replaced with the list of
+// installed files.
+func (p *Wheel) extract(scheme installScheme) error {
+	whlFile, err := zip.OpenReader(p.Path)
+	if err != nil {
+		return err
+	}
+	defer whlFile.Close()
+
+	record, err := readRecord(&whlFile.Reader)
+	if err != nil {
+		return err
+	}
+	distInfo := path.Dir(findDistInfo(whlFile.File, "RECORD").Name)
+
+	var installed []installedFile
+	// The interpreter is only looked up if the wheel contains scripts.
+	python := ""
+	interpreter := func() (string, error) {
+		if python != "" {
+			return python, nil
+		}
+		var err error
+		python, err = env.Interpreter()
+		return python, err
+	}
+	for _, file := range whlFile.File {
+		if file.FileInfo().IsDir() {
+			// Skip directories as parent directories are automatically
+			// created. However, this may cause issues if a package
+			// expects an empty folder in a certain location.
+			continue
+		}
+		if err := checkMode(file.Name, file.Mode()); err != nil {
+			return err
+		}
+
+		target, script, err := scheme.target(file.Name)
+		if err != nil {
+			return err
+		}
+		// Links extracted earlier must not be followed.
+		if err := checkSymlinks(scheme.Prefix, target, file.Name); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+			return err
+		}
+
+		if file.Mode()&os.ModeSymlink != 0 {
+			// The target of the link is verified against RECORD as the
+			// contents of the member.
+			linkname := &bytes.Buffer{}
+			if err := verifyFile(file, record, linkname); err != nil {
+				return err
+			}
+			if !symlinkWithinRoot(scheme.Prefix, target, linkname.String()) {
+				return &UnsafePathError{Member: file.Name, Reason: fmt.Sprintf("symbolic link to '%s' escapes the destination", linkname)}
+			}
+			if err := os.Symlink(linkname.String(), target); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch path.Base(file.Name) {
+		case "RECORD", "INSTALLER":
+			if path.Dir(file.Name) == distInfo {
+				// Replaced after installation.
+				if err := verifyFile(file, record, ioutil.Discard); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		// Write-protected files prevents users from inadvertendly modifying its
+		// dependencies and thereby affecing other projects.
+		var f installedFile
+		if script {
+			python, err := interpreter()
+			if err != nil {
+				return err
+			}
+			f, err = installScript(file, record, target, python)
+		} else {
+			f, err = installFile(file, record, target, 0444)
+		}
+		if err != nil {
+			return err
+		}
+		installed = append(installed, f)
+	}
+
+	distInfoPath := filepath.Join(scheme.Purelib, filepath.FromSlash(distInfo))
+	launchers, err := installLaunchers(distInfoPath, scheme, interpreter)
+	if err != nil {
+		return err
+	}
+	installed = append(installed, launchers...)
+
+	return writeInstallRecord(distInfoPath, scheme.Purelib, installed)
+}
+
+// installFile streams the wheel member to target while verifying it.
+func installFile(file *zip.File, record map[string]recordEntry, target string, perm os.FileMode) (installedFile, error) {
+	dst, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return installedFile{}, err
+	}
+
+	h := sha256.New()
+	if err := verifyFile(file, record, io.MultiWriter(dst, h)); err != nil {
+		dst.Close()
+		return installedFile{}, err
+	}
+
+	if err := dst.Close(); err != nil {
+		return installedFile{}, err
+	}
+
+	return installedFile{path: target, digest: h.Sum(nil), size: int64(file.UncompressedSize64)}, nil
+}
+
+// installScript installs the script after rewriting its shebang.
+func installScript(file *zip.File, record map[string]recordEntry, target, interpreter string) (installedFile, error) {
+	contents := &bytes.Buffer{}
+	if err := verifyFile(file, record, contents); err != nil {
+		return installedFile{}, err
+	}
+
+	rewritten := rewriteShebang(contents.Bytes(), interpreter)
+	if err := writeFile(target, rewritten, 0555); err != nil {
+		return installedFile{}, err
+	}
+
+	sum := sha256.Sum256(rewritten)
+	return installedFile{path: target, digest: sum[:], size: int64(len(rewritten))}, nil
+}
+
+// rewriteShebang replaces the placeholder interpreter of scripts starting
+// with #!python with the path to the interpreter.
+func rewriteShebang(contents []byte, interpreter string) []byte {
+	if !bytes.HasPrefix(contents, []byte("#!python")) {
+		return contents
+	}
+
+	// Preserve any suffix such as #!pythonw and interpreter arguments.
+	rewritten := []byte("#!" + interpreter)
+	return append(rewritten, contents[len("#!python"):]...)
+}
+
+// writeInstallRecord writes the INSTALLER and RECORD files of the installed
+// distribution. Paths are relative to the site-packages directory.
+// https://www.python.org/dev/peps/pep-0376/#record
+func writeInstallRecord(distInfo, sitePackages string, installed []installedFile) error {
+	installer := []byte("rope\n")
+	installerPath := filepath.Join(distInfo, "INSTALLER")
+	if err := writeFile(installerPath, installer, 0444); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(installer)
+	installed = append(installed, installedFile{path: installerPath, digest: sum[:], size: int64(len(installer))})
+
+	recordPath := filepath.Join(distInfo, "RECORD")
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	for _, f := range installed {
+		rel, err := filepath.Rel(sitePackages, f.path)
+		if err != nil {
+			return err
+		}
+		w.Write([]string{
+			filepath.ToSlash(rel),
+			"sha256=" + base64.RawURLEncoding.EncodeToString(f.digest),
+			strconv.FormatInt(f.size, 10),
+		})
+	}
+	rel, err := filepath.Rel(sitePackages, recordPath)
+	if err != nil {
+		return err
+	}
+	w.Write([]string{filepath.ToSlash(rel), "", ""})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return writeFile(recordPath, buf.Bytes(), 0444)
+}
+
+func writeFile(path string, contents []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := dst.Write(contents); err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
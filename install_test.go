@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWheelInstallScheme(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rope-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Provide a fake interpreter to rewrite shebangs with.
+	interpreter := filepath.Join(dir, "python")
+	if err := ioutil.WriteFile(interpreter, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", oldPath)
+	os.Setenv("PATH", dir)
+
+	whlPath := filepath.Join(dir, "example-1.0-py3-none-any.whl")
+	if err := ioutil.WriteFile(whlPath, buildTestWheel(t, []testFile{
+		{"example/__init__.py", []byte("")},
+		{"example-1.0.dist-info/METADATA", []byte("Name: example\n")},
+		{"example-1.0.dist-info/entry_points.txt", []byte("[console_scripts]\nexample-run = example:main\nexample-cli = example:cli\n")},
+		{"example-1.0.data/purelib/example_extra.py", []byte("")},
+		{"example-1.0.data/platlib/example_ext.so", []byte("")},
+		{"example-1.0.data/scripts/example-cli", []byte("#!python -u\nimport example\n")},
+		{"example-1.0.data/scripts/example-sh", []byte("#!/bin/sh\necho example\n")},
+		{"example-1.0.data/headers/example.h", []byte("")},
+		{"example-1.0.data/data/share/example/example.txt", []byte("")},
+	}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := filepath.Join(dir, "install")
+	whl := &Wheel{Path: whlPath}
+	if err := whl.extract(newInstallScheme(prefix, "example")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, path := range []string{
+		"site-packages/example/__init__.py",
+		"site-packages/example_extra.py",
+		"site-packages/example_ext.so",
+		"site-packages/example-1.0.dist-info/METADATA",
+		"site-packages/example-1.0.dist-info/INSTALLER",
+		"bin/example-cli",
+		"bin/example-sh",
+		"bin/example-run",
+		"include/example/example.h",
+		"data/share/example/example.txt",
+	} {
+		if _, err := os.Stat(filepath.Join(prefix, filepath.FromSlash(path))); err != nil {
+			t.Fatalf("expected %s to be installed: %v", path, err)
+		}
+	}
+
+	script, err := ioutil.ReadFile(filepath.Join(prefix, "bin", "example-cli"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "#!" + interpreter + " -u\nimport example\n"; string(script) != expected {
+		t.Fatalf("unexpected script, got: %q, want: %q", script, expected)
+	}
+	if info, err := os.Stat(filepath.Join(prefix, "bin", "example-cli")); err != nil || info.Mode()&0111 == 0 {
+		t.Fatalf("expected script to be executable: %v", err)
+	}
+
+	// Launchers do not replace scripts from the .data directory.
+	launcher, err := ioutil.ReadFile(filepath.Join(prefix, "bin", "example-run"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(launcher), "#!"+interpreter+"\n") || !strings.Contains(string(launcher), "sys.exit(main())") {
+		t.Fatalf("unexpected launcher: %s", launcher)
+	}
+
+	// The installed RECORD must list every installed file with the hash of the
+	// rewritten script.
+	f, err := os.Open(filepath.Join(prefix, "site-packages", "example-1.0.dist-info", "RECORD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	record, err := parseRecord(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(record) != 12 {
+		t.Fatalf("unexpected number of RECORD entries, got: %d, want: 12", len(record))
+	}
+	entry, ok := record["../bin/example-cli"]
+	if !ok {
+		t.Fatalf("expected script in RECORD, got: %v", record)
+	}
+	verifier, err := entry.newVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier.Write(script)
+	if err := verifier.Verify(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestInstallSchemeUnknown(t *testing.T) {
+	scheme := newInstallScheme("prefix", "example")
+	if _, _, err := scheme.target("example-1.0.data/unknown/file"); err == nil || !strings.Contains(err.Error(), "unknown install scheme") {
+		t.Fatalf("expected unknown install scheme error, got: %v", err)
+	}
+	if _, _, err := scheme.target("example-1.0.data/../../file"); err == nil {
+		t.Fatalf("expected error for path traversal")
+	}
+}
@@ -149,6 +149,27 @@ func TestCLI(t *testing.T) {
 			},
 			verification: `import markdown`,
 		},
+		"remove": {
+			steps: []step{
+				{
+					args:             []string{"init"},
+					expectedExitCode: 0,
+				},
+				{
+					args:             []string{"add", "requests==2.24.0"},
+					expectedExitCode: 0,
+				},
+				{
+					// Transitive dependency of requests
+					args:             []string{"remove", "urllib3"},
+					expectedExitCode: 1,
+				},
+				{
+					args:             []string{"remove", "requests"},
+					expectedExitCode: 0,
+				},
+			},
+		},
 	}
 	for name, tc := range testCases {
 		tc := tc
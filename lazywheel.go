@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// errRangeNotSupported is returned when a server does not support range
+// requests.
+var errRangeNotSupported = errors.New("range requests not supported")
+
+// lazyChunkSize is the minimum number of bytes requested at a time. The
+// central directory of most wheels fits in the first chunk read from the end
+// of the file.
+const lazyChunkSize = 64 << 10
+
+type chunk struct {
+	offset int64
+	data   []byte
+}
+
+// httpReaderAt is an io.ReaderAt over a remote file using HTTP range requests.
+// Every downloaded chunk is kept in memory as zip.Reader reads the same regions
+// repeatedly.
+type httpReaderAt struct {
+	ctx    context.Context
+	url    string
+	size   int64
+	chunks []chunk
+}
+
+// newHTTPReaderAt checks that the server supports range requests for the
+// file at url and requests the last chunk of the file. errRangeNotSupported
+// is returned if the server does not advertise support for range requests
+// or refuses HEAD requests.
+func newHTTPReaderAt(ctx context.Context, url string) (*httpReaderAt, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: HEAD request: %s", errRangeNotSupported, res.Status)
+	}
+	if res.Header.Get("Accept-Ranges") != "bytes" || res.ContentLength <= 0 {
+		return nil, errRangeNotSupported
+	}
+
+	r := &httpReaderAt{ctx: ctx, url: url, size: res.ContentLength}
+
+	// Prefetch the end of the file where the central directory is located.
+	off := r.size - lazyChunkSize
+	if off < 0 {
+		off = 0
+	}
+	if _, err := r.ReadAt(make([]byte, 1), off); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Size returns the size of the remote file.
+func (r *httpReaderAt) Size() int64 {
+	return r.size
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *httpReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	var err error
+	if off+int64(len(b)) > r.size {
+		b = b[:r.size-off]
+		err = io.EOF
+	}
+
+	for _, c := range r.chunks {
+		if off >= c.offset && off+int64(len(b)) <= c.offset+int64(len(c.data)) {
+			return copy(b, c.data[off-c.offset:]), err
+		}
+	}
+
+	end := off + int64(len(b))
+	if end < off+lazyChunkSize {
+		end = off + lazyChunkSize
+	}
+	if end > r.size {
+		end = r.size
+	}
+
+	res, getErr := r.get(fmt.Sprintf("bytes=%d-%d", off, end-1))
+	if getErr != nil {
+		return 0, getErr
+	}
+	defer res.Body.Close()
+
+	data := make([]byte, end-off)
+	if _, err := io.ReadFull(res.Body, data); err != nil {
+		return 0, err
+	}
+	r.chunks = append(r.chunks, chunk{offset: off, data: data})
+
+	return copy(b, data), err
+}
+
+func (r *httpReaderAt) get(byteRange string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", byteRange)
+	// Compression would make the byte offsets meaningless.
+	req.Header.Set("Accept-Encoding", "identity")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		return res, nil
+	case http.StatusOK, http.StatusRequestedRangeNotSatisfiable:
+		res.Body.Close()
+		return nil, errRangeNotSupported
+	default:
+		res.Body.Close()
+		return nil, fmt.Errorf("failed HTTP request: %s", res.Status)
+	}
+}
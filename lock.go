@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+)
+
+// fileLock is an exclusive lock held on a file shared between processes.
+type fileLock struct {
+	f *os.File
+}
+
+// lockFile blocks until an exclusive lock is acquired on the file at path.
+// The file is created if it does not exist.
+func lockFile(path string) (*fileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lock(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &fileLock{f: f}, nil
+}
+
+// Unlock releases the lock.
+func (l *fileLock) Unlock() error {
+	if err := unlock(l.f); err != nil {
+		l.f.Close()
+		return err
+	}
+
+	return l.f.Close()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LockedArtifact is the file installed for a package in the build list. The
+// lock of rope.json ensures that the exact same files are installed every
+// time, even if a file is replaced in the package repository.
+type LockedArtifact struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Filename string `json:"filename"`
+	// URL is the location the file was downloaded from stripped of any
+	// credentials.
+	URL string `json:"url,omitempty"`
+	// Sha256 is the hex encoded sha256 digest of the file.
+	Sha256 string `json:"sha256"`
+	// Index is the URL of the package repository the file was found in.
+	Index string `json:"index,omitempty"`
+}
+
+// checkLock verifies that the file selected for the package p is recorded in
+// the lock. The digest recorded in the lock replaces the digest provided by
+// the package repository to ensure the file is verified once downloaded.
+// If strict is false files missing from the lock are allowed as they are
+// about to be added to it. Nothing is verified if the lock is empty.
+func (project *Project) checkLock(p Package, strict bool) error {
+	if len(project.Lock) == 0 {
+		return nil
+	}
+
+	var filename, sum string
+	var pin func(sum string)
+	switch p := p.(type) {
+	case *Wheel:
+		if p.Source != nil {
+			// Built from a source distribution which was verified before
+			// the wheel was added to the cache.
+			filename, sum = p.Source.Filename, p.Source.Sha256
+		} else {
+			filename, sum = p.filename, p.Hashes["sha256"]
+			pin = func(sum string) { p.Hashes = map[string]string{"sha256": sum} }
+		}
+	case *Sdist:
+		filename, sum = p.filename, p.hashes["sha256"]
+		pin = func(sum string) { p.hashes = map[string]string{"sha256": sum} }
+	default:
+		return nil
+	}
+
+	var locked *LockedArtifact
+	for i, a := range project.Lock {
+		if a.Name == p.Name() && a.Version == p.Version().String() && a.Filename == filename {
+			locked = &project.Lock[i]
+			break
+		}
+	}
+	if locked == nil {
+		if strict {
+			return fmt.Errorf("'%s' is not in the lock of rope.json", filename)
+		}
+		return nil
+	}
+
+	if sum != "" && !strings.EqualFold(sum, locked.Sha256) {
+		return fmt.Errorf("'%s' does not match the lock of rope.json, got: sha256=%s, expected: sha256=%s", filename, sum, locked.Sha256)
+	}
+	if pin != nil {
+		pin(locked.Sha256)
+	}
+
+	return nil
+}
+
+// lockedArtifact returns the file installed for the package p.
+func lockedArtifact(p Package) (LockedArtifact, error) {
+	switch p := p.(type) {
+	case *Sdist:
+		if p.wheel == nil {
+			return LockedArtifact{}, fmt.Errorf("'%s' has not been installed", p.filename)
+		}
+		return lockedArtifact(p.wheel)
+	case *Wheel:
+		if p.Source != nil {
+			return *p.Source, nil
+		}
+
+		sum, err := fileSum(p.Path)
+		if err != nil {
+			return LockedArtifact{}, err
+		}
+		return LockedArtifact{
+			Name:     p.name,
+			Version:  p.version.String(),
+			Filename: p.filename,
+			URL:      redactURL(p.URL),
+			Sha256:   hex.EncodeToString(sum),
+			Index:    p.Index,
+		}, nil
+	default:
+		return LockedArtifact{}, fmt.Errorf("unsupported package type: %T", p)
+	}
+}
+
+// updateLock records the installed files of the packages in the build list.
+// Entries of packages no longer in the build list are removed while entries
+// of other files of an installed version, e.g. wheels for other platforms,
+// are kept.
+func (project *Project) updateLock(list []Dependency, installed []LockedArtifact) {
+	inList := make(map[string]bool, len(list))
+	for _, d := range list {
+		inList[d.Name] = true
+	}
+	versions := make(map[string]string, len(installed))
+	filenames := make(map[string]bool, len(installed))
+	for _, a := range installed {
+		versions[a.Name] = a.Version
+		filenames[a.Filename] = true
+	}
+
+	lock := append([]LockedArtifact{}, installed...)
+	for _, a := range project.Lock {
+		if !inList[a.Name] || filenames[a.Filename] {
+			continue
+		}
+		if v, ok := versions[a.Name]; ok && v != a.Version {
+			continue
+		}
+		lock = append(lock, a)
+	}
+
+	sort.Slice(lock, func(i, j int) bool {
+		if lock[i].Name != lock[j].Name {
+			return lock[i].Name < lock[j].Name
+		}
+		return lock[i].Filename < lock[j].Filename
+	})
+	project.Lock = lock
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AlexanderEkdahl/rope/version"
+)
+
+func TestCheckLock(t *testing.T) {
+	project := &Project{Lock: []LockedArtifact{
+		{Name: "example", Version: "1.0", Filename: "example-1.0-py3-none-any.whl", Sha256: "aaaa"},
+		{Name: "other", Version: "2.0", Filename: "other-2.0.tar.gz", Sha256: "bbbb"},
+	}}
+
+	newWheel := func(sum string) *Wheel {
+		whl, err := ParseWheelFilename("example-1.0-py3-none-any.whl")
+		if err != nil {
+			t.Fatal(err)
+		}
+		whl.URL = "https://example.com/example-1.0-py3-none-any.whl"
+		if sum != "" {
+			whl.Hashes = map[string]string{"sha256": sum}
+		}
+		return whl
+	}
+
+	// The digest of the lock is used to verify the download.
+	whl := newWheel("")
+	if err := project.checkLock(whl, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if whl.Hashes["sha256"] != "aaaa" {
+		t.Fatalf("got: %v, want: sha256=aaaa", whl.Hashes)
+	}
+
+	if err := project.checkLock(newWheel("AAAA"), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := project.checkLock(newWheel("cccc"), false)
+	if err == nil || !strings.Contains(err.Error(), "does not match the lock") {
+		t.Fatalf("got: %v, want: mismatch", err)
+	}
+
+	// Wheels built from a source distribution are verified using the digest
+	// of the source distribution.
+	built, _ := ParseWheelFilename("other-2.0-py3-none-any.whl")
+	built.Source = &LockedArtifact{Name: "other", Version: "2.0", Filename: "other-2.0.tar.gz", Sha256: "cccc"}
+	if err := project.checkLock(built, true); err == nil {
+		t.Fatalf("expected mismatch of built wheel")
+	}
+	built.Source.Sha256 = "bbbb"
+	if err := project.checkLock(built, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	missing := &Sdist{name: "missing", version: version.MustParse("1.0"), filename: "missing-1.0.tar.gz"}
+	if err := project.checkLock(missing, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := project.checkLock(missing, true); err == nil {
+		t.Fatalf("expected error for package missing from the lock")
+	}
+
+	// Projects without a lock are not verified.
+	if err := (&Project{}).checkLock(missing, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestUpdateLock(t *testing.T) {
+	project := &Project{Lock: []LockedArtifact{
+		{Name: "example", Version: "1.0", Filename: "example-1.0-cp38-cp38-macosx_10_9_x86_64.whl"},
+		{Name: "example", Version: "1.0", Filename: "example-1.0-cp38-cp38-manylinux1_x86_64.whl", Sha256: "old"},
+		{Name: "removed", Version: "1.0", Filename: "removed-1.0.tar.gz"},
+		{Name: "upgraded", Version: "1.0", Filename: "upgraded-1.0.tar.gz"},
+	}}
+
+	list := []Dependency{
+		{Name: "example", Version: version.MustParse("1.0")},
+		{Name: "upgraded", Version: version.MustParse("2.0")},
+	}
+	project.updateLock(list, []LockedArtifact{
+		{Name: "upgraded", Version: "2.0", Filename: "upgraded-2.0.tar.gz"},
+		{Name: "example", Version: "1.0", Filename: "example-1.0-cp38-cp38-manylinux1_x86_64.whl", Sha256: "new"},
+	})
+
+	want := []LockedArtifact{
+		{Name: "example", Version: "1.0", Filename: "example-1.0-cp38-cp38-macosx_10_9_x86_64.whl"},
+		{Name: "example", Version: "1.0", Filename: "example-1.0-cp38-cp38-manylinux1_x86_64.whl", Sha256: "new"},
+		{Name: "upgraded", Version: "2.0", Filename: "upgraded-2.0.tar.gz"},
+	}
+	if !reflect.DeepEqual(project.Lock, want) {
+		t.Fatalf("got: %+v, want: %+v", project.Lock, want)
+	}
+}
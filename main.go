@@ -3,8 +3,11 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/pflag"
 )
@@ -21,15 +24,19 @@ Usage:
 
 The commands are:
 
-  run          run command with PYTHONPATH configured
+  run          run command with PYTHONPATH and PATH configured
   init         initializes a new rope project
   add          installs and adds one or more dependencies
   remove       removes one or more dependencies
+  sync         installs the dependencies, optionally into a virtual environment
   show         inspect the current dependencies
+  why          explain why a package is a dependency
   export       export dependency specification
   cache        inspecting and clearing the cache
   pythonpath   prints the configured PYTHONPATH
   version      show rope version
+
+Use --offline to only use previously cached package metadata and packages.
 `
 
 // TODO: Figure out how to better interact with this.
@@ -37,9 +44,16 @@ The commands are:
 var cache *Cache
 var env *Environment
 
+// auth holds the credentials for private package indexes and is used by
+// client for every HTTP request.
+var auth = &Auth{}
+var client = &http.Client{Transport: auth}
+
 // Should move the main package into a cli folder and let the top-level package be 'rope'
 // Which can be directly used in the test harness.
 func run(args []string) (int, error) {
+	args, offline := offlineFlag(args)
+
 	arg := ""
 	if len(args) > 1 {
 		arg = args[1]
@@ -48,6 +62,12 @@ func run(args []string) (int, error) {
 	cache = &Cache{}
 	defer cache.Close()
 
+	var err error
+	client, err = newClient(auth, cache, offline)
+	if err != nil {
+		return 1, err
+	}
+
 	// Lazy-loaded environment
 	env = &Environment{}
 
@@ -77,6 +97,8 @@ func run(args []string) (int, error) {
 	case "add":
 		flagSet := pflag.NewFlagSet("install", pflag.ContinueOnError)
 		timeout := flagSet.Duration("timeout", 0, "Command timeout")
+		strict := flagSet.Bool("strict", false, "Fail if a requirement is not satisfied by the selected versions")
+		allowPrereleases := flagSet.Bool("allow-prereleases", false, "Allow pre-releases of the added packages")
 		if err := flagSet.Parse(args[1:]); err == pflag.ErrHelp {
 			return 0, nil
 		} else if err != nil {
@@ -88,24 +110,66 @@ func run(args []string) (int, error) {
 		}
 		packages := flagSet.Args()[1:]
 
-		if err := add(*timeout, packages); err != nil {
+		if err := add(*timeout, *strict, *allowPrereleases, packages); err != nil {
 			return 1, err
 		}
 		return 0, nil
 	case "remove":
-		// TODO: Implement command to remove dependency(error if transitive)
-		return 1, fmt.Errorf("not implemented")
+		flagSet := pflag.NewFlagSet("remove", pflag.ContinueOnError)
+		timeout := flagSet.Duration("timeout", 0, "Command timeout")
+		if err := flagSet.Parse(args[1:]); err == pflag.ErrHelp {
+			return 0, nil
+		} else if err != nil {
+			return 2, err
+		}
+		if len(flagSet.Args()) < 2 {
+			fmt.Println("rope remove: package not provided")
+			return 2, nil
+		}
+		packages := flagSet.Args()[1:]
+
+		if err := remove(*timeout, packages); err != nil {
+			return 1, err
+		}
+		return 0, nil
 	case "export":
-		if err := ExportRequirements(context.Background(), os.Stdout); err != nil {
+		flagSet := pflag.NewFlagSet("export", pflag.ContinueOnError)
+		strict := flagSet.Bool("strict", false, "Fail if a requirement is not satisfied by the selected versions")
+		if err := flagSet.Parse(args[1:]); err == pflag.ErrHelp {
+			return 0, nil
+		} else if err != nil {
+			return 2, err
+		}
+
+		if err := ExportRequirements(context.Background(), os.Stdout, *strict); err != nil {
 			return 1, err
 		}
 		return 0, nil
 	case "show":
-		// TODO: Implement command to show all dependenies along with a tree view
-		return 1, fmt.Errorf("not implemented")
+		flagSet := pflag.NewFlagSet("show", pflag.ContinueOnError)
+		jsonOutput := flagSet.Bool("json", false, "Output the dependency graph as JSON")
+		if err := flagSet.Parse(args[1:]); err == pflag.ErrHelp {
+			return 0, nil
+		} else if err != nil {
+			return 2, err
+		}
+
+		if err := Show(context.Background(), os.Stdout, *jsonOutput); err != nil {
+			return 1, err
+		}
+		return 0, nil
+	case "why":
+		if len(args) < 3 {
+			fmt.Println("rope why: package not provided")
+			return 2, nil
+		}
+
+		if err := Why(context.Background(), os.Stdout, args[2]); err != nil {
+			return 1, err
+		}
+		return 0, nil
 	case "cache":
-		// TODO: Implement operations for show information/clearing the cache
-		return 1, fmt.Errorf("not implemented")
+		return cacheCommand(args[2:])
 	case "pythonpath":
 		pythonPath, err := buildPythonPath(context.Background())
 		if err != nil {
@@ -113,15 +177,66 @@ func run(args []string) (int, error) {
 		}
 		fmt.Printf(pythonPath)
 		return 0, nil
+	case "sync":
+		flagSet := pflag.NewFlagSet("sync", pflag.ContinueOnError)
+		venv := flagSet.Bool("venv", false, "Create a virtual environment in .venv")
+		if err := flagSet.Parse(args[1:]); err == pflag.ErrHelp {
+			return 0, nil
+		} else if err != nil {
+			return 2, err
+		}
+
+		if err := syncProject(context.Background(), *venv); err != nil {
+			return 1, err
+		}
+		return 0, nil
 	case "run":
-		pythonPath, err := buildPythonPath(context.Background())
+		if len(args) < 3 {
+			fmt.Println("rope run: command not provided")
+			return 2, nil
+		}
+
+		paths, err := installDependencies(context.Background())
+		if err != nil {
+			return 1, err
+		}
+
+		// Prefer the virtual environment of the project if it has been created
+		// using rope sync --venv.
+		venv, err := projectVenv()
 		if err != nil {
 			return 1, err
 		}
+		useVenv := isRopeVenv(venv)
+
+		environ := os.Environ()
+		var bin string
+		if useVenv {
+			if err := syncVenv(venv, paths); err != nil {
+				return 1, err
+			}
+			bin = filepath.Join(venv, "bin")
+			environ = append(environ, fmt.Sprintf("VIRTUAL_ENV=%s", venv))
+		} else {
+			bin, err = linkScripts(paths)
+			if err != nil {
+				return 1, err
+			}
+			// TODO: Merge any provided PYTHONPATH with the new one?
+			environ = append(environ, fmt.Sprintf("PYTHONPATH=%s", strings.Join(paths, string(os.PathListSeparator))))
+		}
+
+		// The command is resolved using the PATH of the command rather than the
+		// PATH of rope.
+		name := args[2]
+		if !strings.ContainsRune(name, filepath.Separator) {
+			if _, err := os.Stat(filepath.Join(bin, name)); err == nil {
+				name = filepath.Join(bin, name)
+			}
+		}
 
-		cmd := exec.Command(args[2], args[3:]...)
-		// TODO: Merge any provided PYTHONPATH with the new one?
-		cmd.Env = append(os.Environ(), fmt.Sprintf("PYTHONPATH=%s", pythonPath))
+		cmd := exec.Command(name, args[3:]...)
+		cmd.Env = append(environ, fmt.Sprintf("PATH=%s%c%s", bin, os.PathListSeparator, os.Getenv("PATH")))
 		cmd.Stdin = os.Stdin
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
@@ -141,6 +256,21 @@ func run(args []string) (int, error) {
 	}
 }
 
+// offlineFlag removes the global --offline flag from args. The arguments of
+// the command executed by rope run are left untouched.
+func offlineFlag(args []string) ([]string, bool) {
+	offline := false
+	filtered := make([]string, 0, len(args))
+	for i, arg := range args {
+		if i > 0 && arg == "--offline" && !(len(filtered) > 2 && filtered[1] == "run") {
+			offline = true
+			continue
+		}
+		filtered = append(filtered, arg)
+	}
+	return filtered, offline
+}
+
 func main() {
 	exitCode, err := run(os.Args)
 	if err != nil {
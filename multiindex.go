@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/AlexanderEkdahl/rope/version"
+)
+
+// MultiIndex composes multiple package indexes. Packages are searched for in
+// each index in priority order and the first index knowing the name of the
+// package is used even if it lacks the requested version or a compatible
+// distribution. Falling through to later indexes, e.g. public PyPI, would
+// otherwise allow anyone to publish a package shadowing a version of an
+// internal package. Packages pinned to an index are only searched for in
+// that index.
+type MultiIndex struct {
+	indexes []PackageIndex
+	// pins maps canonical package names to the position of an index.
+	pins        map[string]int
+	prereleases *Prereleases
+}
+
+// FindPackage finds the package in the first index knowing the name of the
+// package.
+func (m *MultiIndex) FindPackage(ctx context.Context, name string, v version.Version) (Package, error) {
+	name = NormalizePackageName(name)
+	if i, ok := m.pins[name]; ok {
+		return m.indexes[i].FindPackage(ctx, name, v)
+	}
+
+	for _, index := range m.indexes {
+		p, err := index.FindPackage(ctx, name, v)
+		if errors.Is(err, ErrPackageNotFound) {
+			continue
+		}
+
+		return p, err
+	}
+
+	return nil, ErrPackageNotFound
+}
+
+func (m *MultiIndex) allowPrereleases(name string) bool {
+	return m.prereleases.Allowed(name)
+}
+
+// Versions returns the versions of the package in the same index as
+// FindPackage finds the package in. Nothing is returned if that index, or an
+// index preceding it, is unable to list versions. Whether such an index knows
+// the package can only be determined by resolving the package which may
+// require downloading it.
+func (m *MultiIndex) Versions(ctx context.Context, name string) ([]version.Version, error) {
+	name = NormalizePackageName(name)
+	if i, ok := m.pins[name]; ok {
+		if lister, ok := m.indexes[i].(versionLister); ok {
+			return lister.Versions(ctx, name)
+		}
+		return nil, nil
+	}
+
+	for _, index := range m.indexes {
+		lister, ok := index.(versionLister)
+		if !ok {
+			// Later indexes must not be used as the index may know the
+			// package.
+			return nil, nil
+		}
+
+		vs, err := lister.Versions(ctx, name)
+		if errors.Is(err, ErrPackageNotFound) {
+			continue
+		}
+
+		return vs, err
+	}
+
+	return nil, ErrPackageNotFound
+}
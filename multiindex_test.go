@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AlexanderEkdahl/rope/version"
+)
+
+func TestMultiIndex(t *testing.T) {
+	primary := &testPackageIndex{
+		map[string][]testPackage{
+			"torch": {{name: "torch", version: version.MustParse("1.6.0+cu101")}},
+		},
+	}
+	secondary := &testPackageIndex{
+		map[string][]testPackage{
+			"torch": {{name: "torch", version: version.MustParse("1.6.0")}},
+			"numpy": {{name: "numpy", version: version.MustParse("1.19.2")}},
+		},
+	}
+
+	ctx := context.Background()
+	m := &MultiIndex{indexes: []PackageIndex{primary, secondary}}
+
+	if p, err := m.FindPackage(ctx, "torch", version.Version{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if p.Version().LocalVersion != "cu101" {
+		t.Fatalf("expected package from primary index, got: %s", p.Version())
+	}
+	if p, err := m.FindPackage(ctx, "numpy", version.Version{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if p.Name() != "numpy" {
+		t.Fatalf("expected numpy, got: %s", p.Name())
+	}
+	if _, err := m.FindPackage(ctx, "scipy", version.Version{}); !errors.Is(err, ErrPackageNotFound) {
+		t.Fatalf("expected ErrPackageNotFound, got: %v", err)
+	}
+	// Versions missing from the first index knowing the package are never
+	// searched for in later indexes.
+	if _, err := m.FindPackage(ctx, "torch", version.MustParse("1.6.0")); !errors.Is(err, ErrCompatiblePackageNotFound) {
+		t.Fatalf("expected ErrCompatiblePackageNotFound, got: %v", err)
+	}
+
+	m.pins = map[string]int{"torch": 1, "numpy": 0}
+	if p, err := m.FindPackage(ctx, "torch", version.Version{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if p.Version().LocalVersion != "" {
+		t.Fatalf("expected package from pinned index, got: %s", p.Version())
+	}
+	if _, err := m.FindPackage(ctx, "numpy", version.Version{}); !errors.Is(err, ErrPackageNotFound) {
+		t.Fatalf("expected pinned package to only be searched for in its index, got: %v", err)
+	}
+
+	m.pins = nil
+	if vs, err := m.Versions(ctx, "numpy"); err != nil || len(vs) != 1 || vs[0].String() != "1.19.2" {
+		t.Fatalf("got: %v, %v, want: [1.19.2]", vs, err)
+	}
+	// Versions are listed from the same index as packages are found in.
+	if vs, err := m.Versions(ctx, "torch"); err != nil || len(vs) != 1 || vs[0].LocalVersion != "cu101" {
+		t.Fatalf("got: %v, %v, want: [1.6.0+cu101]", vs, err)
+	}
+	if _, err := m.Versions(ctx, "scipy"); !errors.Is(err, ErrPackageNotFound) {
+		t.Fatalf("expected ErrPackageNotFound, got: %v", err)
+	}
+
+	// Indexes unable to list versions are not resolved to list versions and
+	// later indexes are not used.
+	resolver := &resolvingIndex{index: primary}
+	m.indexes = []PackageIndex{resolver, secondary}
+	if vs, err := m.Versions(ctx, "numpy"); err != nil || len(vs) != 0 {
+		t.Fatalf("got: %v, %v, want: no versions", vs, err)
+	}
+	if resolver.found != 0 {
+		t.Fatalf("expected no package to be resolved, got: %d", resolver.found)
+	}
+}
+
+// resolvingIndex is a package index unable to list versions.
+type resolvingIndex struct {
+	index PackageIndex
+	found int
+}
+
+func (i *resolvingIndex) FindPackage(ctx context.Context, name string, v version.Version) (Package, error) {
+	i.found++
+	return i.index.FindPackage(ctx, name, v)
+}
+
+func TestProjectPackageIndex(t *testing.T) {
+	project := &Project{
+		Indexes: []IndexConfig{
+			{Name: "pytorch", Type: IndexTypeLinks, URL: "https://download.pytorch.org/whl/torch_stable.html", Packages: []string{"torch"}},
+			{Name: "pypi", Type: IndexTypePyPI},
+		},
+	}
+	index, err := project.PackageIndex()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := index.(*MultiIndex)
+	if len(m.indexes) != 2 || m.pins["torch"] != 0 {
+		t.Fatalf("unexpected index: %+v", m)
+	}
+
+	invalid := []Project{
+		{Indexes: []IndexConfig{{Name: "a", Type: IndexTypeSimple}}},
+		{Indexes: []IndexConfig{{Name: "a", Type: "unknown", URL: "https://example.com"}}},
+		{Indexes: []IndexConfig{{Type: IndexTypePyPI}}},
+		{Indexes: []IndexConfig{{Name: "a", Type: IndexTypePyPI}, {Name: "a", Type: IndexTypePyPI}}},
+		{Indexes: []IndexConfig{{Name: "a", Type: IndexTypePyPI, Packages: []string{"x"}}, {Name: "b", Type: IndexTypePyPI, Packages: []string{"X"}}}},
+	}
+	for _, p := range invalid {
+		if _, err := p.PackageIndex(); err == nil {
+			t.Fatalf("expected error for indexes: %+v", p.Indexes)
+		}
+	}
+}
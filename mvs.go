@@ -9,14 +9,23 @@ import (
 	"github.com/AlexanderEkdahl/rope/version"
 )
 
+// ErrPackageNotFound is returned when the name of the package is unknown to
+// an index.
 var ErrPackageNotFound = errors.New("package not found")
 
+// ErrCompatiblePackageNotFound is returned when a package exists in an index
+// but the requested version or a distribution compatible with the current
+// environment does not.
+var ErrCompatiblePackageNotFound = errors.New("compatible package not found")
+
 type Package interface {
 	// Name must be normalized in its canonical form
 	Name() string
 	Version() version.Version
-	// Dependency names must be normalized in its canonical form
-	Dependencies() []Dependency
+	// Dependencies returns the dependencies of the package including the
+	// dependencies of the provided extras. Dependency names must be
+	// normalized in its canonical form
+	Dependencies(extras []string) []Dependency
 	Install(context.Context) (string, error)
 }
 
@@ -24,6 +33,21 @@ type PackageIndex interface {
 	FindPackage(ctx context.Context, name string, v version.Version) (Package, error)
 }
 
+// versionLister is implemented by package indexes able to list every version
+// of a package. Such indexes allow minimal version selection to select the
+// lowest version satisfying every specifier instead of the lower bound of
+// the specifiers, e.g. 1.0.1 for '>=1.0, !=1.0'.
+type versionLister interface {
+	Versions(ctx context.Context, name string) ([]version.Version, error)
+}
+
+// prereleasePolicy is implemented by package indexes configured to allow
+// pre-releases of some packages to be selected even if a final release
+// satisfies the specifiers.
+type prereleasePolicy interface {
+	allowPrereleases(name string) bool
+}
+
 // MinimalVersionSelection recursively visits every dependency's dependencies and builds
 // a list of the minimal version required by each dependency. This list is then reduced
 // to remove duplicate dependencies by only keeping the greatest version of each entry.
@@ -56,6 +80,114 @@ func MinimalVersionSelection(ctx context.Context, base []Dependency, index Packa
 	visited := make(map[string]struct{})
 	buildDependencies := make(map[string]node)
 
+	// requirements holds the requirements of rope.json and of every package
+	// in the build list indexed by the name of the dependency and the name of
+	// the dependant. The requirements of rope.json are stored under the empty
+	// name. Requirements of versions that have been replaced are dropped as
+	// they no longer constrain the build list.
+	requirements := make(map[string]map[string][]Dependency)
+	require := func(dependant string, previous, dependencies []Dependency) {
+		for _, d := range previous {
+			delete(requirements[d.Name], dependant)
+		}
+		for _, d := range dependencies {
+			if requirements[d.Name] == nil {
+				requirements[d.Name] = make(map[string][]Dependency)
+			}
+			requirements[d.Name][dependant] = append(requirements[d.Name][dependant], d)
+		}
+	}
+	// constraints returns the intersection of the specifiers of every
+	// requirement of the package.
+	constraints := func(name string) version.SpecifierSet {
+		dependantNames := make([]string, 0, len(requirements[name]))
+		for dependant := range requirements[name] {
+			dependantNames = append(dependantNames, dependant)
+		}
+		sort.Strings(dependantNames)
+
+		var s version.SpecifierSet
+		for _, dependant := range dependantNames {
+			for _, d := range requirements[name][dependant] {
+				s = s.Intersect(d.Specifiers)
+			}
+		}
+		return s
+	}
+	// selectedBy describes the requirements forcing the version currently
+	// selected for every package.
+	selectedBy := make(map[string][]string)
+	// dependants maps the identifier of every enqueued dependency to the
+	// package that first required it.
+	dependants := make(map[string]string)
+	dependencyID := func(d Dependency) string {
+		return d.Name + formatExtras(d.Extras) + d.Version.String() + d.Specifiers.String()
+	}
+
+	lister, _ := index.(versionLister)
+	policy, _ := index.(prereleasePolicy)
+	versions := make(map[string][]version.Version)
+	// satisfying returns the versions of the package satisfying every
+	// specifier seen so far in ascending order.
+	satisfying := func(name string) ([]version.Version, error) {
+		vs, ok := versions[name]
+		if !ok {
+			var err error
+			vs, err = lister.Versions(ctx, name)
+			if err != nil && !errors.Is(err, ErrPackageNotFound) {
+				return nil, fmt.Errorf("listing versions of '%s': %w", name, err)
+			}
+			versions[name] = vs
+		}
+
+		return constraints(name).Filter(vs), nil
+	}
+	// Final releases are preferred over pre-releases unless pre-releases
+	// are explicitly requested or allowed.
+	allowPrereleases := func(name string) bool {
+		return constraints(name).Prereleases() || policy != nil && policy.allowPrereleases(name)
+	}
+	// lowest returns the lowest satisfying version of the package that is at
+	// least v. The zero version is returned if no version is known to
+	// satisfy the specifiers.
+	lowest := func(name string, v version.Version) (version.Version, error) {
+		vs, err := satisfying(name)
+		if err != nil {
+			return version.Version{}, err
+		}
+
+		var candidates []version.Version
+		for _, candidate := range vs {
+			if !v.GreaterThan(candidate) {
+				candidates = append(candidates, candidate)
+			}
+		}
+
+		candidate, _ := selectVersion(candidates, allowPrereleases(name))
+		return candidate, nil
+	}
+	// latest returns the greatest satisfying version of the package. The
+	// zero version is returned if no version is known to satisfy the
+	// specifiers.
+	latest := func(name string) (version.Version, error) {
+		vs, err := satisfying(name)
+		if err != nil {
+			return version.Version{}, err
+		}
+
+		candidates := make([]version.Version, 0, len(vs))
+		for i := len(vs) - 1; i >= 0; i-- {
+			candidates = append(candidates, vs[i])
+		}
+
+		candidate, _ := selectVersion(candidates, allowPrereleases(name))
+		return candidate, nil
+	}
+
+	for _, d := range base {
+		dependants[dependencyID(d)] = "rope.json"
+	}
+	require("", nil, base)
 	work := append([]Dependency{}, base...)
 	for len(work) > 0 {
 		// To avoid having to convert sdists uneccessarly the algorithm could keep 2 lists.
@@ -76,36 +208,103 @@ func MinimalVersionSelection(ctx context.Context, base []Dependency, index Packa
 		d := work[0]
 		work = work[1:]
 
-		if v, ok := buildDependencies[d.Name]; !ok || replace(v.value, d.Version, d.Version.Unspecified()) {
-			// if ok {
-			// 	fmt.Printf("🧩 replacing %s-%s with %s-%s\n", v.value.Name, v.value.Version, d.Name, d.Version)
-			// }
-
-			p, err := index.FindPackage(ctx, d.Name, d.Version)
-			if err != nil {
-				return nil, nil, fmt.Errorf("finding package '%s-%s': %w", d.Name, d.Version, err)
+		source := fmt.Sprintf("%s requires %s", dependants[dependencyID(d)], requirementLabel(d))
+		unspecified := d.Version.Unspecified()
+		if lister != nil && len(d.Specifiers) > 0 {
+			if !unspecified || lowerBounded(d.Specifiers) {
+				// The selected version may be excluded by the specifiers.
+				floor := d.Version
+				if v, ok := buildDependencies[d.Name]; ok && v.value.Version.GreaterThan(floor) {
+					floor = v.value.Version
+				}
+				candidate, err := lowest(d.Name, floor)
+				if err != nil {
+					return nil, nil, err
+				}
+				if candidate.GreaterThan(d.Version) {
+					d.Version = candidate
+				}
+				unspecified = d.Version.Unspecified()
+			} else {
+				// Upper bounds and exclusions such as '<2' select the latest
+				// satisfying version rather than the oldest. The dependency
+				// remains unspecified as the selected version changes once a
+				// new version is released.
+				candidate, err := latest(d.Name)
+				if err != nil {
+					return nil, nil, err
+				}
+				d.Version = candidate
 			}
+		}
+
+		// Every package pulls in the union of the dependencies of the extras
+		// requested by its dependants.
+		v, ok := buildDependencies[d.Name]
+		extras := d.Extras
+		if ok {
+			extras = unionExtras(v.value.Extras, d.Extras)
+		}
 
-			buildDependencies[p.Name()] = node{
-				value: Dependency{
-					Name:        p.Name(),
-					Version:     p.Version(),
-					Unspecified: d.Version.Unspecified(),
-					Mismatch:    !d.Version.Unspecified() && !p.Version().Equal(d.Version),
-				},
-				dependencies: p.Dependencies(),
+		value := Dependency{
+			Name:        d.Name,
+			Version:     d.Version,
+			Unspecified: unspecified,
+		}
+		// The latest version selected for an unspecified dependency is
+		// replaced if excluded by the specifiers, e.g. 'numpy<2'.
+		excluded := ok && v.value.Unspecified && !d.Version.Unspecified() && !constraints(d.Name).Contains(v.value.Version)
+		revisit := ok && !excluded && !replace(v.value, d.Version, unspecified)
+		if revisit {
+			same := unspecified && v.value.Unspecified || !unspecified && d.Version.Equal(v.value.Version)
+			if same && !containsString(selectedBy[d.Name], source) {
+				selectedBy[d.Name] = append(selectedBy[d.Name], source)
 			}
+			if len(extras) == len(v.value.Extras) {
+				continue
+			}
+			// Revisit the selected version to add the dependencies of the
+			// newly requested extras.
+			value = v.value
+		}
 
-			for _, d := range p.Dependencies() {
-				dependencyID := d.Name + d.Version.String()
-				if _, ok := visited[dependencyID]; ok {
-					// prevent cycles
-					continue
-				}
-				visited[dependencyID] = struct{}{}
+		// if ok {
+		// 	fmt.Printf("🧩 replacing %s-%s with %s-%s\n", v.value.Name, v.value.Version, d.Name, d.Version)
+		// }
+
+		p, err := index.FindPackage(ctx, value.Name, value.Version)
+		if err != nil {
+			return nil, nil, fmt.Errorf("finding package '%s-%s': %w", value.Name, value.Version, err)
+		}
+		if !revisit {
+			value.Mismatch = !unspecified && !p.Version().Equal(d.Version)
+			selectedBy[d.Name] = []string{source}
+		}
+		value.Name = p.Name()
+		value.Version = p.Version()
+		value.Extras = extras
+
+		dependencies := p.Dependencies(extras)
+		var previous []Dependency
+		if v, ok := buildDependencies[p.Name()]; ok {
+			previous = v.dependencies
+		}
+		require(p.Name(), previous, dependencies)
+		buildDependencies[p.Name()] = node{
+			value:        value,
+			dependencies: dependencies,
+		}
 
-				work = append(work, d)
+		for _, d := range dependencies {
+			id := dependencyID(d)
+			if _, ok := visited[id]; ok {
+				// prevent cycles
+				continue
 			}
+			visited[id] = struct{}{}
+			dependants[id] = fmt.Sprintf("%s %s", p.Name(), p.Version().Canonical())
+
+			work = append(work, d)
 		}
 	}
 
@@ -167,6 +366,7 @@ func MinimalVersionSelection(ctx context.Context, base []Dependency, index Packa
 		minimalDependencies[d.Name] = Dependency{
 			Name:    d.Name,
 			Version: v,
+			Extras:  unionExtras(minimalDependencies[d.Name].Extras, d.Extras),
 		}
 		walk2(d)
 	}
@@ -189,12 +389,42 @@ func MinimalVersionSelection(ctx context.Context, base []Dependency, index Packa
 		minimalDependencies[name] = buildDependencies[name].value
 	}
 
+	// Requirements are checked once every version has been selected as
+	// minimal version selection only follows lower bounds. A requirement not
+	// satisfied by the selected version, e.g. 'numpy<1.20' when another
+	// package requires 'numpy>=1.21', is recorded as a conflict.
 	buildList := make([]Dependency, 0, len(buildDependencies))
 	for _, node := range buildDependencies {
-		buildList = append(buildList, node.value)
+		selected := node.value
+		selected.Specifiers = constraints(selected.Name)
+		selected.SelectedBy = selectedBy[selected.Name]
+		var conflicts []Conflict
+		for dependant, ds := range requirements[selected.Name] {
+			if dependant == "" {
+				continue
+			}
+			for _, d := range ds {
+				if !d.Specifiers.Contains(selected.Version) {
+					conflicts = append(conflicts, Conflict{
+						Dependant:  dependant,
+						Dependency: d,
+						Selected:   selected,
+					})
+				}
+			}
+		}
+		sortConflicts(conflicts)
+		selected.Conflicts = conflicts
+		buildList = append(buildList, selected)
+	}
+	// Settings of direct dependencies are kept in the minimal list.
+	prereleaseDependencies := make(map[string]bool)
+	for _, d := range base {
+		prereleaseDependencies[d.Name] = prereleaseDependencies[d.Name] || d.AllowPrereleases
 	}
 	minimalList := make([]Dependency, 0, len(minimalDependencies))
 	for _, node := range minimalDependencies {
+		node.AllowPrereleases = prereleaseDependencies[node.Name]
 		minimalList = append(minimalList, node)
 	}
 
@@ -208,3 +438,24 @@ func MinimalVersionSelection(ctx context.Context, base []Dependency, index Packa
 
 	return buildList, minimalList, nil
 }
+
+// lowerBounded returns true if the specifiers exclude every version below
+// some version.
+func lowerBounded(s version.SpecifierSet) bool {
+	for _, vr := range s {
+		switch vr.Operator {
+		case version.Greater, version.GreaterOrEqual, version.CompatibleEqual, version.Equal, version.TripleEqual:
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, s2 := range list {
+		if s2 == s {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"sort"
 	"testing"
 
 	"github.com/AlexanderEkdahl/rope/version"
@@ -22,17 +23,32 @@ func (pi *testPackageIndex) FindPackage(ctx context.Context, name string, v vers
 		}
 	}
 
-	if foundPackage == nil {
+	if foundPackage == nil && len(pi.index[name]) > 0 {
+		return nil, ErrCompatiblePackageNotFound
+	} else if foundPackage == nil {
 		return nil, ErrPackageNotFound
 	}
 
 	return foundPackage, nil
 }
 
+func (pi *testPackageIndex) Versions(ctx context.Context, name string) ([]version.Version, error) {
+	var vs []version.Version
+	for _, p := range pi.index[name] {
+		vs = append(vs, p.version)
+	}
+	if len(vs) == 0 {
+		return nil, ErrPackageNotFound
+	}
+	return vs, nil
+}
+
 type testPackage struct {
 	name         string
 	version      version.Version
 	dependencies []Dependency
+	// extras maps extras to their additional dependencies.
+	extras map[string][]Dependency
 }
 
 func (p testPackage) Name() string {
@@ -43,8 +59,12 @@ func (p testPackage) Version() version.Version {
 	return p.version
 }
 
-func (p testPackage) Dependencies() []Dependency {
-	return p.dependencies
+func (p testPackage) Dependencies(extras []string) []Dependency {
+	dependencies := p.dependencies
+	for _, extra := range extras {
+		dependencies = append(dependencies, p.extras[extra]...)
+	}
+	return dependencies
 }
 
 func (p testPackage) Install(context.Context) (string, error) {
@@ -572,6 +592,361 @@ func TestVersionSelectionUnboundedReproduceable(t *testing.T) {
 	)
 }
 
+func TestVersionSelectionExtras(t *testing.T) {
+	index := &testPackageIndex{
+		map[string][]testPackage{
+			"requests": {
+				{
+					name:    "requests",
+					version: version.MustParse("2.24"),
+					dependencies: []Dependency{
+						{
+							Name:    "urllib3",
+							Version: version.MustParse("1.25"),
+						},
+					},
+				},
+			},
+			"botocore": {
+				{
+					name:    "botocore",
+					version: version.MustParse("1.17"),
+					dependencies: []Dependency{
+						{
+							Name:    "urllib3",
+							Version: version.MustParse("1.25"),
+							Extras:  []string{"secure"},
+						},
+					},
+				},
+			},
+			"urllib3": {
+				{
+					name:    "urllib3",
+					version: version.MustParse("1.25"),
+					extras: map[string][]Dependency{
+						"secure": {
+							{
+								Name:    "certifi",
+								Version: version.MustParse("2020.6.20"),
+							},
+						},
+						"socks": {
+							{
+								Name:    "pysocks",
+								Version: version.MustParse("1.7"),
+							},
+						},
+					},
+				},
+			},
+			"certifi": {
+				{
+					name:    "certifi",
+					version: version.MustParse("2020.6.20"),
+				},
+			},
+			"pysocks": {
+				{
+					name:    "pysocks",
+					version: version.MustParse("1.7"),
+				},
+			},
+		},
+	}
+
+	// urllib3 is first visited without extras through requests and must be
+	// revisited once botocore requests the secure extra.
+	base := []Dependency{
+		{
+			Name: "requests",
+		},
+		{
+			Name: "botocore",
+		},
+	}
+	build := []Dependency{
+		{
+			Name:    "botocore",
+			Version: version.MustParse("1.17"),
+		},
+		{
+			Name:    "certifi",
+			Version: version.MustParse("2020.6.20"),
+		},
+		{
+			Name:    "requests",
+			Version: version.MustParse("2.24"),
+		},
+		{
+			Name:    "urllib3",
+			Version: version.MustParse("1.25"),
+		},
+	}
+	verifyMinimalVersionSelection(t, index, base, build, nil)
+
+	list, _, err := MinimalVersionSelection(context.Background(), base, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := list[3].Extras; len(got) != 1 || got[0] != "secure" {
+		t.Fatalf("got: %v, want: [secure]", got)
+	}
+
+	// Extras of direct dependencies are kept in the minimal list.
+	base = append(base, Dependency{Name: "urllib3", Extras: []string{"socks"}})
+	_, minimal, err := MinimalVersionSelection(context.Background(), base, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range minimal {
+		if d.Name == "urllib3" && formatExtras(d.Extras) != "[socks]" {
+			t.Fatalf("got: %v, want: [socks]", d.Extras)
+		}
+	}
+}
+
+func TestVersionSelectionSpecifiers(t *testing.T) {
+	specifiers := func(s string) version.SpecifierSet {
+		set, err := version.ParseSpecifierSet(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return set
+	}
+
+	index := &testPackageIndex{
+		map[string][]testPackage{
+			"A": {
+				{
+					name:    "A",
+					version: version.MustParse("1.0"),
+					dependencies: []Dependency{
+						{
+							Name:       "C",
+							Version:    version.MustParse("1.0"),
+							Specifiers: specifiers(">=1.0, !=1.0"),
+						},
+					},
+				},
+			},
+			"B": {
+				{
+					name:    "B",
+					version: version.MustParse("1.0"),
+					dependencies: []Dependency{
+						{
+							Name:       "C",
+							Version:    version.MustParse("1.0"),
+							Specifiers: specifiers(">=1.0, !=1.0.1"),
+						},
+					},
+				},
+			},
+			"D": {
+				{
+					name:    "D",
+					version: version.MustParse("1.0"),
+					dependencies: []Dependency{
+						{
+							Name:       "C",
+							Version:    version.MustParse("1.1rc1"),
+							Specifiers: specifiers(">=1.1rc1"),
+						},
+					},
+				},
+			},
+			"C": {
+				{
+					name:    "C",
+					version: version.MustParse("1.0"),
+				},
+				{
+					name:    "C",
+					version: version.MustParse("1.0.1"),
+				},
+				{
+					name:    "C",
+					version: version.MustParse("1.1rc1"),
+				},
+				{
+					name:    "C",
+					version: version.MustParse("1.1"),
+				},
+				{
+					name:    "C",
+					version: version.MustParse("1.2"),
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		base []Dependency
+		want string
+	}{
+		// The lowest version satisfying '!=1.0' is selected.
+		{[]Dependency{{Name: "A", Version: version.MustParse("1.0")}}, "1.0.1"},
+		// Every dependant excludes a version and pre-releases are skipped.
+		{[]Dependency{{Name: "A", Version: version.MustParse("1.0")}, {Name: "B", Version: version.MustParse("1.0")}}, "1.1"},
+		{[]Dependency{{Name: "B", Version: version.MustParse("1.0")}, {Name: "A", Version: version.MustParse("1.0")}}, "1.1"},
+		// Explicitly requested pre-releases are selected.
+		{[]Dependency{{Name: "D", Version: version.MustParse("1.0")}}, "1.1rc1"},
+	}
+	for _, test := range tests {
+		build := []Dependency{
+			{
+				Name:    "C",
+				Version: version.MustParse(test.want),
+			},
+		}
+		build = append(build, test.base...)
+		sort.Slice(build, func(i, j int) bool {
+			return build[i].Name < build[j].Name
+		})
+		verifyMinimalVersionSelection(t, index, test.base, build, nil)
+	}
+}
+
+func TestVersionSelectionUpperBounds(t *testing.T) {
+	specifiers := func(s string) version.SpecifierSet {
+		set, err := version.ParseSpecifierSet(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return set
+	}
+	dependant := func(name, requirement string) testPackage {
+		return testPackage{
+			name:    name,
+			version: version.MustParse("1.0"),
+			dependencies: []Dependency{
+				{
+					Name:        "numpy",
+					Version:     version.Minimal(specifiers(requirement)),
+					Requirement: "numpy" + requirement,
+					Specifiers:  specifiers(requirement),
+				},
+			},
+		}
+	}
+
+	index := &testPackageIndex{
+		map[string][]testPackage{
+			"A": {dependant("A", "<2")},
+			"B": {dependant("B", "!=1.26")},
+			"C": {dependant("C", ">=2")},
+			"numpy": {
+				{name: "numpy", version: version.MustParse("1.25")},
+				{name: "numpy", version: version.MustParse("1.26")},
+				{name: "numpy", version: version.MustParse("2.0")},
+			},
+		},
+	}
+
+	tests := []struct {
+		base []string
+		want string
+	}{
+		// The latest version satisfying the upper bound is selected.
+		{[]string{"A"}, "1.26"},
+		{[]string{"B"}, "2.0"},
+		{[]string{"A", "B"}, "1.25"},
+		// The latest version selected for an unspecified dependency is
+		// replaced once excluded.
+		{[]string{"numpy", "A"}, "1.26"},
+	}
+	for _, test := range tests {
+		var base []Dependency
+		for _, name := range test.base {
+			base = append(base, Dependency{Name: name, Version: version.MustParse("1.0")})
+		}
+		if test.base[0] == "numpy" {
+			base[0].Version = version.Version{}
+		}
+
+		build, minimal, err := MinimalVersionSelection(context.Background(), base, index)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", test.base, err)
+		}
+		for _, d := range build {
+			if d.Name == "numpy" && d.Version.String() != test.want {
+				t.Fatalf("%v: got: %s, want: %s", test.base, d.Version, test.want)
+			}
+		}
+		// Upper bounds do not pin the version.
+		found := false
+		for _, d := range minimal {
+			found = found || d.Name == "numpy"
+		}
+		if !found {
+			t.Fatalf("%v: expected numpy in the minimal list, got: %v", test.base, minimal)
+		}
+	}
+
+	// Requirements no version can satisfy do not fail the selection. The
+	// lower bound is followed and the violated requirement recorded.
+	base := []Dependency{
+		{Name: "C", Version: version.MustParse("1.0")},
+		{Name: "A", Version: version.MustParse("1.0")},
+	}
+	build, _, err := MinimalVersionSelection(context.Background(), base, index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conflicts := Conflicts(build)
+	if len(conflicts) != 1 || conflicts[0].Dependant != "A" || conflicts[0].Selected.Version.String() != "2.0" {
+		t.Fatalf("got: %v, want: A requiring numpy<2", conflicts)
+	}
+}
+
+func TestVersionSelectionSupersededRequirements(t *testing.T) {
+	dependency := func(name, requirement string) Dependency {
+		s, err := version.ParseSpecifierSet(requirement)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Dependency{Name: name, Version: version.Minimal(s), Requirement: name + requirement, Specifiers: s}
+	}
+	// x 2.0 requiring 'c<1.5' is replaced by x 3.0 requiring 'c>=2.0'.
+	index := &testPackageIndex{
+		map[string][]testPackage{
+			"a": {{name: "a", version: version.MustParse("1.0"), dependencies: []Dependency{dependency("x", ">=2.0")}}},
+			"b": {{name: "b", version: version.MustParse("1.0"), dependencies: []Dependency{dependency("x", ">=3.0")}}},
+			"x": {
+				{name: "x", version: version.MustParse("2.0"), dependencies: []Dependency{dependency("c", "<1.5")}},
+				{name: "x", version: version.MustParse("3.0"), dependencies: []Dependency{dependency("c", ">=2.0")}},
+			},
+			"c": {
+				{name: "c", version: version.MustParse("1.4")},
+				{name: "c", version: version.MustParse("2.0")},
+				{name: "c", version: version.MustParse("2.1")},
+			},
+		},
+	}
+	base := []Dependency{
+		{Name: "a", Version: version.MustParse("1.0")},
+		{Name: "b", Version: version.MustParse("1.0")},
+	}
+
+	build, _, err := MinimalVersionSelection(context.Background(), base, index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{"a": "1.0", "b": "1.0", "x": "3.0", "c": "2.0"}
+	if len(build) != len(want) {
+		t.Fatalf("got: %v, want: %v", build, want)
+	}
+	for _, d := range build {
+		if d.Version.String() != want[d.Name] {
+			t.Fatalf("%s: got: %s, want: %s", d.Name, d.Version, want[d.Name])
+		}
+	}
+	if conflicts := Conflicts(build); len(conflicts) != 0 {
+		t.Fatalf("got: %v, want: no conflicts", conflicts)
+	}
+}
+
 func verifyMinimalVersionSelection(
 	t *testing.T,
 	index PackageIndex,
@@ -604,3 +979,57 @@ func verifyMinimalVersionSelection(
 		}
 	}
 }
+
+func TestVersionSelectionKeepsSettings(t *testing.T) {
+	index := &testPackageIndex{
+		map[string][]testPackage{
+			"A": {{name: "A", version: version.MustParse("1.0rc1")}},
+			"B": {{name: "B", version: version.MustParse("1.0")}},
+		},
+	}
+	base := []Dependency{
+		{Name: "A", Version: version.MustParse("1.0rc1"), AllowPrereleases: true},
+		{Name: "B", Version: version.MustParse("1.0")},
+	}
+
+	_, minimal, err := MinimalVersionSelection(context.Background(), base, index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(minimal) != 2 || !minimal[0].AllowPrereleases || minimal[1].AllowPrereleases {
+		t.Fatalf("got: %+v, want: allow-prereleases kept for A only", minimal)
+	}
+}
+
+func TestVersionSelectionSelectedBy(t *testing.T) {
+	dependency := func(name, v string) Dependency {
+		return Dependency{Name: name, Version: version.MustParse(v)}
+	}
+	// x 2.0 is replaced by x 3.0 but its requirement still forces c 1.5.
+	index := &testPackageIndex{
+		map[string][]testPackage{
+			"a": {{name: "a", version: version.MustParse("1.0"), dependencies: []Dependency{dependency("x", "2.0")}}},
+			"b": {{name: "b", version: version.MustParse("1.0"), dependencies: []Dependency{dependency("x", "3.0")}}},
+			"x": {
+				{name: "x", version: version.MustParse("2.0"), dependencies: []Dependency{dependency("c", "1.5")}},
+				{name: "x", version: version.MustParse("3.0"), dependencies: []Dependency{dependency("c", "1.0")}},
+			},
+			"c": {{name: "c", version: version.MustParse("1.0")}, {name: "c", version: version.MustParse("1.5")}},
+		},
+	}
+
+	build, _, err := MinimalVersionSelection(context.Background(), []Dependency{dependency("a", "1.0"), dependency("b", "1.0")}, index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"a": "rope.json requires 'a>=1.0'",
+		"c": "x 2.0 requires 'c>=1.5'",
+		"x": "b 1.0 requires 'x>=3.0'",
+	}
+	for _, d := range build {
+		if w, ok := want[d.Name]; ok && (len(d.SelectedBy) != 1 || d.SelectedBy[0] != w) {
+			t.Fatalf("%s: got: %q, want: %q", d.Name, d.SelectedBy, w)
+		}
+	}
+}
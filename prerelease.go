@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/AlexanderEkdahl/rope/version"
+)
+
+// Prereleases is the allow-prereleases setting of rope.json. Pre-releases are
+// only selected when explicitly requested, e.g. 'torch>=2.0rc1', or when no
+// final release matches. The setting allows pre-releases of every
+// package(true) or of the listed packages(["torch"]) to be selected even if
+// a final release matches.
+// https://www.python.org/dev/peps/pep-0440/#handling-of-pre-releases
+type Prereleases struct {
+	All bool
+	// Packages are the canonical names of the packages.
+	Packages []string
+}
+
+func (p *Prereleases) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &p.All); err == nil {
+		p.Packages = nil
+		return nil
+	}
+
+	var packages []string
+	if err := json.Unmarshal(b, &packages); err != nil {
+		return fmt.Errorf("allow-prereleases: expected a boolean or a list of packages, got: %s", b)
+	}
+
+	p.All = false
+	p.Packages = make([]string, 0, len(packages))
+	for _, name := range packages {
+		p.Packages = append(p.Packages, NormalizePackageName(name))
+	}
+	return nil
+}
+
+func (p Prereleases) MarshalJSON() ([]byte, error) {
+	if p.Packages == nil {
+		return json.Marshal(p.All)
+	}
+	return json.Marshal(p.Packages)
+}
+
+// Allowed returns true if pre-releases of the package may be selected even if
+// a final release matches.
+func (p *Prereleases) Allowed(name string) bool {
+	if p == nil {
+		return false
+	} else if p.All {
+		return true
+	}
+
+	name = NormalizePackageName(name)
+	for _, n := range p.Packages {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// selectVersion returns the first candidate in order of preference that is
+// not a pre-release. If allowPrereleases is true or every candidate is a
+// pre-release the first candidate is returned. False is returned if there
+// are no candidates.
+func selectVersion(candidates []version.Version, allowPrereleases bool) (version.Version, bool) {
+	if len(candidates) == 0 {
+		return version.Version{}, false
+	}
+	if allowPrereleases {
+		return candidates[0], true
+	}
+
+	for _, v := range candidates {
+		if !v.Prerelease() {
+			return v, true
+		}
+	}
+	return candidates[0], true
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/AlexanderEkdahl/rope/version"
+)
+
+func TestPrereleasesJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		allowed map[string]bool
+	}{
+		{`{"dependencies": []}`, map[string]bool{"torch": false}},
+		{`{"dependencies": [], "allow-prereleases": false}`, map[string]bool{"torch": false}},
+		{`{"dependencies": [], "allow-prereleases": true}`, map[string]bool{"torch": true, "numpy": true}},
+		{`{"dependencies": [], "allow-prereleases": ["Torch"]}`, map[string]bool{"torch": true, "numpy": false}},
+	}
+
+	for _, test := range tests {
+		var project Project
+		if err := json.Unmarshal([]byte(test.input), &project); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.input, err)
+		}
+		for name, want := range test.allowed {
+			if got := project.AllowPrereleases.Allowed(name); got != want {
+				t.Fatalf("%s: %s: got: %v, want: %v", test.input, name, got, want)
+			}
+		}
+	}
+
+	b, err := json.Marshal(&Project{AllowPrereleases: &Prereleases{Packages: []string{"torch"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `{"dependencies":null,"allow-prereleases":["torch"]}`; string(b) != want {
+		t.Fatalf("got: %s, want: %s", b, want)
+	}
+
+	var project Project
+	if err := json.Unmarshal([]byte(`{"allow-prereleases": "torch"}`), &project); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestProjectPrereleases(t *testing.T) {
+	var project Project
+	input := `{
+		"dependencies": ["numpy-1.19.0", {"dependency": "torch-2.1.0rc1", "allow-prereleases": true}],
+		"allow-prereleases": ["scipy"]
+	}`
+	if err := json.Unmarshal([]byte(input), &project); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	policy := project.prereleases([]string{"Pandas"})
+	for name, want := range map[string]bool{"torch": true, "scipy": true, "pandas": true, "numpy": false} {
+		if got := policy.Allowed(name); got != want {
+			t.Fatalf("%s: got: %v, want: %v", name, got, want)
+		}
+	}
+	if project.AllowPrereleases.Allowed("torch") {
+		t.Fatalf("project policy modified")
+	}
+}
+
+func TestSelectPackagePrereleases(t *testing.T) {
+	files := func(filenames ...string) []simpleFile {
+		var files []simpleFile
+		for _, filename := range filenames {
+			files = append(files, simpleFile{Filename: filename, URL: "https://example.com/" + filename})
+		}
+		return files
+	}
+
+	tests := []struct {
+		files []simpleFile
+		v     string
+		allow bool
+		want  string
+	}{
+		{files("example-1.0.tar.gz", "example-2.0rc1.tar.gz"), "", false, "1.0"},
+		{files("example-1.0.tar.gz", "example-2.0rc1.tar.gz"), "", true, "2.0rc1"},
+		// Pre-releases are selected if nothing else matches.
+		{files("example-2.0a1.tar.gz", "example-2.0rc1.tar.gz"), "", false, "2.0rc1"},
+		// Explicitly requested pre-releases are always selected.
+		{files("example-1.0.tar.gz", "example-2.0rc1.tar.gz"), "2.0rc1", false, "2.0rc1"},
+	}
+
+	for _, test := range tests {
+		v, _ := version.Parse(test.v)
+		p, err := selectPackage(test.files, v, "https://example.com", test.allow)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := p.Version().String(); got != test.want {
+			t.Fatalf("%v, allow: %v: got: %s, want: %s", test.files, test.allow, got, test.want)
+		}
+	}
+}
+
+func TestPyPIPrereleases(t *testing.T) {
+	releases := json.RawMessage(`{
+		"1.0": [{"filename": "example-1.0.tar.gz"}],
+		"1.1rc1": [{"filename": "example-1.1rc1.tar.gz"}],
+		"1.1": [{"filename": "example-1.1.tar.gz"}],
+		"1.2b1": [{"filename": "example-1.2b1.tar.gz"}]
+	}`)
+	atLeast := func(v string) version.SpecifierSet {
+		return version.SpecifierSet{{Operator: version.GreaterOrEqual, Version: version.MustParse(v)}}
+	}
+
+	tests := []struct {
+		index   *PyPI
+		min     version.SpecifierSet
+		wantMin string
+		wantMax string
+	}{
+		{&PyPI{}, atLeast("1.0.1"), "1.1", "1.1"},
+		{&PyPI{}, atLeast("1.1rc1"), "1.1rc1", "1.1"},
+		{&PyPI{}, atLeast("1.1.1"), "1.2b1", "1.1"},
+		{&PyPI{prereleases: &Prereleases{Packages: []string{"example"}}}, atLeast("1.0.1"), "1.1rc1", "1.2b1"},
+		{&PyPI{prereleases: &Prereleases{Packages: []string{"other"}}}, atLeast("1.0.1"), "1.1", "1.1"},
+	}
+
+	for _, test := range tests {
+		min, err := test.index.findMin("example", releases, test.min)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if min.String() != test.wantMin {
+			t.Fatalf("%s: got: %s, want: %s", test.min, min, test.wantMin)
+		}
+
+		max, err := test.index.findMax("example", releases)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if max.String() != test.wantMax {
+			t.Fatalf("%s: got: %s, want: %s", test.min, max, test.wantMax)
+		}
+	}
+}
@@ -3,20 +3,154 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/AlexanderEkdahl/rope/version"
 )
 
 type Project struct {
-	Python       string       `json:"python,omitempty"`
-	Dependencies []Dependency `json:"dependencies"`
+	Python string `json:"python,omitempty"`
+	// Indexes are the package indexes used to resolve dependencies in
+	// priority order. The Python Package Index is used if empty.
+	Indexes      []IndexConfig `json:"indexes,omitempty"`
+	Dependencies []Dependency  `json:"dependencies"`
+	// AllowPrereleases allows pre-releases to be selected for every package
+	// or for the listed packages. Dependencies may also allow pre-releases
+	// individually.
+	AllowPrereleases *Prereleases `json:"allow-prereleases,omitempty"`
+	// Lock records the file selected for every package in the build list.
+	// Packages are only installed if the file is found in the lock.
+	Lock []LockedArtifact `json:"lock,omitempty"`
+}
+
+// Types of package indexes that can be configured.
+const (
+	// IndexTypeSimple is a PEP 503 simple repository.
+	IndexTypeSimple = "simple"
+	// IndexTypeLinks is a single HTML page linking to distributions such as
+	// the PyTorch wheel page.
+	IndexTypeLinks = "links"
+	// IndexTypePyPI is the JSON API exposed by the Python Package Index.
+	IndexTypePyPI = "pypi"
+)
+
+// IndexConfig configures a single package index.
+type IndexConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	// Packages are only ever resolved using this index.
+	Packages []string `json:"packages,omitempty"`
+}
+
+// PackageIndex returns the package index composed from the configured
+// indexes. Every command resolving packages must use this index.
+func (p *Project) PackageIndex() (PackageIndex, error) {
+	return p.packageIndex(nil)
+}
+
+// packageIndex returns the package index composed from the configured
+// indexes additionally allowing pre-releases of the named packages.
+func (p *Project) packageIndex(allowPrereleases []string) (PackageIndex, error) {
+	prereleases := p.prereleases(allowPrereleases)
+	if len(p.Indexes) == 0 {
+		return &MultiIndex{
+			indexes:     []PackageIndex{&PyPI{prereleases: prereleases}},
+			prereleases: prereleases,
+		}, nil
+	}
+
+	m := &MultiIndex{
+		pins:        make(map[string]int),
+		prereleases: prereleases,
+	}
+	names := make(map[string]bool)
+	for i, config := range p.Indexes {
+		if config.Name == "" {
+			return nil, fmt.Errorf("index %d: missing name", i)
+		} else if names[config.Name] {
+			return nil, fmt.Errorf("index '%s': duplicate name", config.Name)
+		}
+		names[config.Name] = true
+
+		index, err := config.index(auth, prereleases)
+		if err != nil {
+			return nil, fmt.Errorf("index '%s': %w", config.Name, err)
+		}
+		m.indexes = append(m.indexes, index)
+
+		for _, name := range config.Packages {
+			name = NormalizePackageName(name)
+			if j, ok := m.pins[name]; ok {
+				return nil, fmt.Errorf("'%s' pinned to both index '%s' and '%s'", name, p.Indexes[j].Name, config.Name)
+			}
+			m.pins[name] = i
+		}
+	}
+
+	return m, nil
+}
+
+// prereleases returns the pre-release policy of the project including the
+// dependencies allowing pre-releases and the named packages.
+func (p *Project) prereleases(names []string) *Prereleases {
+	names = append([]string{}, names...)
+	for _, d := range p.Dependencies {
+		if d.AllowPrereleases {
+			names = append(names, d.Name)
+		}
+	}
+	if len(names) == 0 {
+		return p.AllowPrereleases
+	}
+
+	policy := &Prereleases{}
+	if p.AllowPrereleases != nil {
+		policy.All = p.AllowPrereleases.All
+		policy.Packages = append(policy.Packages, p.AllowPrereleases.Packages...)
+	}
+	for _, name := range names {
+		policy.Packages = append(policy.Packages, NormalizePackageName(name))
+	}
+	return policy
+}
+
+// index instantiates the configured index and registers any credentials for
+// the index with auth.
+func (c IndexConfig) index(auth *Auth, prereleases *Prereleases) (PackageIndex, error) {
+	if c.URL == "" && c.Type != IndexTypePyPI {
+		return nil, fmt.Errorf("missing url")
+	}
+
+	url := c.URL
+	if url != "" {
+		var err error
+		url, err = auth.AddIndex(c.Name, c.URL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch c.Type {
+	case IndexTypeSimple:
+		return &Index{url: url, prereleases: prereleases}, nil
+	case IndexTypeLinks:
+		return &LinkIndex{url: url, prereleases: prereleases}, nil
+	case IndexTypePyPI:
+		return &PyPI{url: url, prereleases: prereleases}, nil
+	default:
+		return nil, fmt.Errorf("unknown type '%s', expected one of: %s, %s, %s", c.Type, IndexTypeSimple, IndexTypeLinks, IndexTypePyPI)
+	}
 }
 
 type Dependency struct {
 	// Name is the canonical name of the package
 	Name    string
 	Version version.Version
+	// Extras are the normalized optional features of the package whose
+	// dependencies are also required, e.g. 'secure' in 'urllib3[secure]'.
+	Extras []string
 
 	// Unspecified is true if no version constraint is applied
 	// to this dependency. Special care must be taken in this
@@ -28,19 +162,63 @@ type Dependency struct {
 	// Mismatch is true if the found is not equal to the version
 	// specified by dependants.
 	Mismatch bool
+
+	// Requirement is the PEP 508 requirement from which this dependency was
+	// derived. Empty for dependencies read from rope.json.
+	Requirement string
+
+	// Specifiers are the version specifiers of the requirement. Version is
+	// the minimal version of the specifiers. In a build list they are the
+	// intersection of the specifiers of every requirement of the package.
+	Specifiers version.SpecifierSet
+
+	// SelectedBy describes the requirements that forced the version in a
+	// build list, e.g. "b 1.0 requires 'numpy>=1.21'". Requirements of
+	// versions that were visited but not selected are included.
+	SelectedBy []string
+
+	// Conflicts are the requirements of packages in a build list that are
+	// not satisfied by the selected version.
+	Conflicts []Conflict
+
+	// AllowPrereleases allows pre-releases of the dependency to be selected
+	// even if a final release matches. Only read from rope.json.
+	AllowPrereleases bool
+}
+
+// dependencyJSON is the form of dependencies in rope.json with additional
+// settings:
+//
+// 	{"dependency": "torch-2.1.0rc1", "allow-prereleases": true}
+type dependencyJSON struct {
+	Dependency       string `json:"dependency"`
+	AllowPrereleases bool   `json:"allow-prereleases,omitempty"`
 }
 
 func (d *Dependency) UnmarshalJSON(b []byte) error {
 	var s string
 	if err := json.Unmarshal(b, &s); err != nil {
-		return err
+		var dj dependencyJSON
+		if err := json.Unmarshal(b, &dj); err != nil {
+			return fmt.Errorf("expected dependency to be a string or an object: %w", err)
+		}
+		s = dj.Dependency
+		d.AllowPrereleases = dj.AllowPrereleases
 	}
 
 	sep := strings.LastIndex(s, "-")
 	if sep < 0 {
-		return fmt.Errorf("expected dependency to be in the form of <name>-<version>, got: '%s'", s)
+		return fmt.Errorf("expected dependency to be in the form of <name>[<extras>]-<version>, got: '%s'", s)
 	}
-	d.Name = NormalizePackageName(string(s[:sep]))
+	name := s[:sep]
+	if open := strings.Index(name, "["); open >= 0 {
+		if !strings.HasSuffix(name, "]") {
+			return fmt.Errorf("expected dependency to be in the form of <name>[<extras>]-<version>, got: '%s'", s)
+		}
+		d.Extras = normalizeExtras(strings.Split(name[open+1:len(name)-1], ","))
+		name = name[:open]
+	}
+	d.Name = NormalizePackageName(name)
 
 	var valid bool
 	d.Version, valid = version.Parse(s[sep+1:])
@@ -55,5 +233,41 @@ func (d Dependency) MarshalJSON() ([]byte, error) {
 	if d.Version.Unspecified() {
 		return nil, fmt.Errorf("marshaling unspecified version for '%s'", d.Name)
 	}
-	return json.Marshal(fmt.Sprintf("%s-%s", d.Name, d.Version))
+	s := fmt.Sprintf("%s%s-%s", d.Name, formatExtras(d.Extras), d.Version)
+	if d.AllowPrereleases {
+		return json.Marshal(dependencyJSON{Dependency: s, AllowPrereleases: true})
+	}
+	return json.Marshal(s)
+}
+
+// normalizeExtras normalizes the names of extras(PEP 685) and returns them
+// sorted without duplicates.
+func normalizeExtras(extras []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(extras))
+	for _, e := range extras {
+		e = version.NormalizeExtra(strings.TrimSpace(e))
+		if e == "" || seen[e] {
+			continue
+		}
+		seen[e] = true
+		normalized = append(normalized, e)
+	}
+
+	sort.Strings(normalized)
+	return normalized
+}
+
+// unionExtras returns the normalized union of the extras a and b.
+func unionExtras(a, b []string) []string {
+	return normalizeExtras(append(append([]string{}, a...), b...))
+}
+
+// formatExtras formats extras as they are written in a requirement, e.g.
+// '[secure,socks]'. An empty string is returned if there are no extras.
+func formatExtras(extras []string) string {
+	if len(extras) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(extras, ",") + "]"
 }
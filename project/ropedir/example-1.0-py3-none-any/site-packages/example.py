@@ -0,0 +1 @@
+rebuilt
\ No newline at end of file
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDependencyJSON(t *testing.T) {
+	testCases := []struct {
+		input  string
+		name   string
+		extras []string
+		output string
+	}{
+		{`"requests-2.24.0"`, "requests", nil, `"requests-2.24.0"`},
+		{`"urllib3[secure]-1.25.10"`, "urllib3", []string{"secure"}, `"urllib3[secure]-1.25.10"`},
+		{`"Urllib3[Socks, secure]-1.25.10"`, "urllib3", []string{"secure", "socks"}, `"urllib3[secure,socks]-1.25.10"`},
+	}
+	for _, tc := range testCases {
+		var d Dependency
+		if err := json.Unmarshal([]byte(tc.input), &d); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.input, err)
+		}
+		if d.Name != tc.name || !reflect.DeepEqual(d.Extras, tc.extras) {
+			t.Fatalf("%s: got: %s%v, want: %s%v", tc.input, d.Name, d.Extras, tc.name, tc.extras)
+		}
+
+		b, err := json.Marshal(d)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.input, err)
+		}
+		if string(b) != tc.output {
+			t.Fatalf("got: %s, want: %s", b, tc.output)
+		}
+	}
+
+	var d Dependency
+	if err := json.Unmarshal([]byte(`"urllib3[secure-1.25.10"`), &d); err == nil {
+		t.Fatalf("expected error for unterminated extras")
+	}
+
+	input := `{"dependency":"Torch-2.1.0rc1","allow-prereleases":true}`
+	d = Dependency{}
+	if err := json.Unmarshal([]byte(input), &d); err != nil {
+		t.Fatalf("%s: unexpected error: %v", input, err)
+	}
+	if d.Name != "torch" || d.Version.String() != "2.1.0rc1" || !d.AllowPrereleases {
+		t.Fatalf("%s: got: %+v", input, d)
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("%s: unexpected error: %v", input, err)
+	}
+	if want := `{"dependency":"torch-2.1.0rc1","allow-prereleases":true}`; string(b) != want {
+		t.Fatalf("got: %s, want: %s", b, want)
+	}
+}
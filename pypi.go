@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"os"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/AlexanderEkdahl/rope/version"
@@ -15,7 +17,24 @@ import (
 // PyPI is a repository of software for the Python programming language.
 // This index exposes a JSON API for directly accessing information about
 // dependencies.
-type PyPI struct{}
+//
+// If url is empty the Python Package Index is used.
+type PyPI struct {
+	url         string
+	prereleases *Prereleases
+
+	mu sync.Mutex
+	// releases maps canonical package names to the releases listed on the
+	// project page of the package.
+	releases map[string]json.RawMessage
+}
+
+func (i *PyPI) baseURL() string {
+	if i.url == "" {
+		return PythonPackageIndex
+	}
+	return strings.TrimSuffix(i.url, "/")
+}
 
 // FindPackage searches the PyPI repository for the specified package and version.
 // If the exact version can not be found the search is relaxed. This means that
@@ -23,24 +42,24 @@ type PyPI struct{}
 func (i *PyPI) FindPackage(ctx context.Context, name string, v version.Version) (Package, error) {
 	name = NormalizePackageName(name)
 
-	cachedWheel, err := checkCache(ctx, name, v)
+	cachedWheel, err := checkCache(ctx, i.baseURL(), name, v)
 	if err != nil {
 		return nil, err
 	} else if cachedWheel != nil {
 		return cachedWheel, nil
 	}
 
-	url := fmt.Sprintf("%s/pypi/%s/%s/json", PythonPackageIndex, name, v)
+	url := fmt.Sprintf("%s/pypi/%s/%s/json", i.baseURL(), name, v)
 	if v.Unspecified() {
-		url = fmt.Sprintf("%s/pypi/%s/json", PythonPackageIndex, name)
+		url = fmt.Sprintf("%s/pypi/%s/json", i.baseURL(), name)
 	}
 
-	r, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	r, err := newMetadataRequest(ctx, url)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := http.DefaultClient.Do(r)
+	res, err := client.Do(r)
 	if err != nil {
 		return nil, err
 	}
@@ -55,23 +74,12 @@ func (i *PyPI) FindPackage(ctx context.Context, name string, v version.Version)
 		}
 
 		// If the specific version can not be found; find the next version available.
-		r, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/pypi/%s/json", PythonPackageIndex, name), nil)
-		if err != nil {
-			return nil, err
-		}
-
-		res, err := http.DefaultClient.Do(r)
+		releases, err := i.projectReleases(ctx, name)
 		if err != nil {
 			return nil, err
 		}
-		defer res.Body.Close()
-
-		var resData pypiResponse
-		if json.NewDecoder(res.Body).Decode(&resData); err != nil {
-			return nil, fmt.Errorf("decoding JSON response: %w", err)
-		}
 
-		newVersion, err := i.findMin(resData.Releases, v)
+		newVersion, err := i.findMin(name, releases, version.SpecifierSet{{Operator: version.GreaterOrEqual, Version: v}})
 		if err != nil {
 			return nil, err
 		}
@@ -89,12 +97,27 @@ func (i *PyPI) FindPackage(ctx context.Context, name string, v version.Version)
 	if json.NewDecoder(res.Body).Decode(&resData); err != nil {
 		return nil, fmt.Errorf("decoding JSON response: %w", err)
 	}
+	if v.Unspecified() {
+		i.rememberReleases(name, resData.Releases)
+	}
+
+	// The latest version reported by PyPI is never a pre-release unless
+	// every release is a pre-release.
+	if v.Unspecified() && i.prereleases.Allowed(name) {
+		latest, err := i.findMax(name, resData.Releases)
+		if err != nil {
+			return nil, err
+		}
+		if current, ok := version.Parse(resData.Info.Version); !ok || latest.GreaterThan(current) {
+			return i.FindPackage(ctx, name, latest)
+		}
+	}
 
 	// In some cases the list of urls for a specified version is empty.
 	// Relax the search in the same way as in the case for when a version
 	// can not be found.
 	if len(resData.URLs) == 0 {
-		newVersion, err := i.findMin(resData.Releases, v)
+		newVersion, err := i.findMin(name, resData.Releases, version.SpecifierSet{{Operator: version.GreaterOrEqual, Version: v}})
 		if err != nil {
 			return nil, err
 		}
@@ -117,6 +140,9 @@ func (i *PyPI) FindPackage(ctx context.Context, name string, v version.Version)
 				return nil, err
 			}
 			whl.URL = url.URL
+			whl.Index = i.baseURL()
+			whl.Hashes = map[string]string{"sha256": url.Digests.Sha256}
+			whl.Yanked = url.Yanked
 			whl.RequiresDist = resData.Info.RequiresDist
 			whl.RequiresPython = url.RequiresPython
 
@@ -136,6 +162,10 @@ func (i *PyPI) FindPackage(ctx context.Context, name string, v version.Version)
 				return nil, err
 			}
 			sdist.url = url.URL
+			sdist.index = i.baseURL()
+			sdist.hashes = map[string]string{"sha256": url.Digests.Sha256}
+			sdist.requiresPython = url.RequiresPython
+			sdist.yanked = url.Yanked
 
 			foundPackages = append(foundPackages, sdist)
 		case "bdist_egg":
@@ -149,7 +179,7 @@ func (i *PyPI) FindPackage(ctx context.Context, name string, v version.Version)
 		if v.Unspecified() {
 			// The version of the Python interpreter is likely unsupported.
 			// Try to find the maximum version that is supported.
-			newVersion, err := i.findMax(resData.Releases)
+			newVersion, err := i.findMax(name, resData.Releases)
 			if err != nil {
 				return nil, err
 			}
@@ -158,56 +188,113 @@ func (i *PyPI) FindPackage(ctx context.Context, name string, v version.Version)
 			return i.FindPackage(ctx, name, newVersion)
 		}
 
-		return nil, fmt.Errorf("compatible package not found")
+		return nil, ErrCompatiblePackageNotFound
 	}
 
 	return selectPrefered(foundPackages, env), nil
 }
 
-// findMin finds the minimal version that is greater than or equal to the the given version min.
-func (i *PyPI) findMin(releasesJSON json.RawMessage, min version.Version) (version.Version, error) {
-	releases := map[string][]pypiRelease{}
-	if err := json.Unmarshal(releasesJSON, &releases); err != nil {
-		return version.Version{}, fmt.Errorf("unmarshalling releases: %w", err)
+// findMin finds the minimal version of the package satisfying every
+// requirement of the set. Pre-releases are only selected if explicitly
+// requested, allowed or if no final release satisfies the set.
+func (i *PyPI) findMin(name string, releasesJSON json.RawMessage, set version.SpecifierSet) (version.Version, error) {
+	vs, err := releaseVersions(releasesJSON)
+	if err != nil {
+		return version.Version{}, err
 	}
 
-	vs := make([]version.Version, 0, len(releases))
-	for k, release := range releases {
-		if len(release) == 0 {
-			continue
-		}
+	min, ok := selectVersion(set.Filter(vs), set.Prereleases() || i.prereleases.Allowed(name))
+	if !ok {
+		return version.Version{}, ErrCompatiblePackageNotFound
+	}
+	return min, nil
+}
 
-		v, valid := version.Parse(k)
-		if !valid {
-			continue
-		}
+// findMax finds the maximal version of the package. Pre-releases are only
+// selected if allowed or if every release is a pre-release.
+func (i *PyPI) findMax(name string, releasesJSON json.RawMessage) (version.Version, error) {
+	vs, err := releaseVersions(releasesJSON)
+	if err != nil {
+		return version.Version{}, err
+	}
 
-		if version.Compare(v, min) < 0 {
-			continue
-		}
+	sort.Slice(vs, func(a, b int) bool {
+		return version.Compare(vs[a], vs[b]) > 0
+	})
+	max, ok := selectVersion(vs, i.prereleases.Allowed(name))
+	if !ok {
+		return version.Version{}, ErrCompatiblePackageNotFound
+	}
+	return max, nil
+}
 
-		if ok, _ := env.SatisfiesPythonVersion(release[0].RequiresPython); !ok {
-			continue
-		}
+// Versions returns every version of the package with at least one file
+// supporting the version of the Python interpreter.
+func (i *PyPI) Versions(ctx context.Context, name string) ([]version.Version, error) {
+	releases, err := i.projectReleases(ctx, NormalizePackageName(name))
+	if err != nil {
+		return nil, err
+	}
 
-		vs = append(vs, v)
+	return releaseVersions(releases)
+}
+
+// projectReleases returns the releases listed on the project page of the
+// package. Pages are remembered as they are needed both to relax the search
+// for a version and to list versions during version selection.
+func (i *PyPI) projectReleases(ctx context.Context, name string) (json.RawMessage, error) {
+	i.mu.Lock()
+	releases, ok := i.releases[name]
+	i.mu.Unlock()
+	if ok {
+		return releases, nil
+	}
+
+	r, err := newMetadataRequest(ctx, fmt.Sprintf("%s/pypi/%s/json", i.baseURL(), name))
+	if err != nil {
+		return nil, err
 	}
 
-	if len(vs) == 0 {
-		return version.Version{}, ErrPackageNotFound
+	res, err := client.Do(r)
+	if err != nil {
+		return nil, err
 	}
+	defer res.Body.Close()
 
-	// TODO: This should only include preleases if nothing else matches
-	sort.Slice(vs, func(i, j int) bool {
-		return version.Compare(vs[i], vs[j]) < 0
-	})
-	return vs[0], nil
+	switch res.StatusCode {
+	case http.StatusOK:
+		// continue
+	case http.StatusNotFound:
+		return nil, ErrPackageNotFound
+	default:
+		return nil, fmt.Errorf("failed HTTP request: %s", res.Status)
+	}
+
+	var resData pypiResponse
+	if err := json.NewDecoder(res.Body).Decode(&resData); err != nil {
+		return nil, fmt.Errorf("decoding JSON response: %w", err)
+	}
+
+	i.rememberReleases(name, resData.Releases)
+	return resData.Releases, nil
 }
 
-func (i *PyPI) findMax(releasesJSON json.RawMessage) (version.Version, error) {
+func (i *PyPI) rememberReleases(name string, releases json.RawMessage) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.releases == nil {
+		i.releases = make(map[string]json.RawMessage)
+	}
+	i.releases[name] = releases
+}
+
+// releaseVersions returns the valid versions of the releases whose files
+// support the version of the Python interpreter.
+func releaseVersions(releasesJSON json.RawMessage) ([]version.Version, error) {
 	releases := map[string][]pypiRelease{}
 	if err := json.Unmarshal(releasesJSON, &releases); err != nil {
-		return version.Version{}, fmt.Errorf("unmarshalling releases: %w", err)
+		return nil, fmt.Errorf("unmarshalling releases: %w", err)
 	}
 
 	vs := make([]version.Version, 0, len(releases))
@@ -221,11 +308,6 @@ func (i *PyPI) findMax(releasesJSON json.RawMessage) (version.Version, error) {
 			continue
 		}
 
-		// TODO: Overly simplistic method of excluding pre-releases
-		if v.PreReleasePhase < 0 {
-			continue
-		}
-
 		if ok, _ := env.SatisfiesPythonVersion(release[0].RequiresPython); !ok {
 			continue
 		}
@@ -233,15 +315,7 @@ func (i *PyPI) findMax(releasesJSON json.RawMessage) (version.Version, error) {
 		vs = append(vs, v)
 	}
 
-	if len(vs) == 0 {
-		return version.Version{}, ErrPackageNotFound
-	}
-
-	// TODO: This should only include preleases if nothing else matches
-	sort.Slice(vs, func(i, j int) bool {
-		return version.Compare(vs[i], vs[j]) > 0
-	})
-	return vs[0], nil
+	return vs, nil
 }
 
 type pypiResponse struct {
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AlexanderEkdahl/rope/version"
+)
+
+func TestPyPIVersions(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pypi/example/json", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{
+			"info": {"version": "1.1"},
+			"urls": [{"filename": "example-1.1.tar.gz", "packagetype": "sdist", "url": "https://example.com/example-1.1.tar.gz"}],
+			"releases": {"1.0": [{"filename": "example-1.0.tar.gz"}], "1.1": [{"filename": "example-1.1.tar.gz"}], "1.2": []}
+		}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	oldCache := cache
+	defer func() {
+		cache = oldCache
+	}()
+	cache = &Cache{Temporary: true}
+	defer cache.Close()
+
+	index := &PyPI{url: server.URL}
+	if _, err := index.FindPackage(context.Background(), "example", version.Version{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The project page fetched by FindPackage is reused.
+	vs, err := index.Versions(context.Background(), "Example")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := version.SpecifierSet(nil).Filter(vs); len(got) != 2 || got[0].String() != "1.0" || got[1].String() != "1.1" {
+		t.Fatalf("got: %v, want: [1.0 1.1]", got)
+	}
+	if requests != 1 {
+		t.Fatalf("got: %d requests, want: 1", requests)
+	}
+}
@@ -2,43 +2,126 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
-func buildPythonPath(ctx context.Context) (string, error) {
+// installDependencies installs every package in the build list and returns
+// the paths to add to PYTHONPATH.
+func installDependencies(ctx context.Context) ([]string, error) {
 	project, err := ReadRopefile()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	index := &Index{
-		url: DefaultIndex,
+	index, err := project.PackageIndex()
+	if err != nil {
+		return nil, err
 	}
 	list, _, err := MinimalVersionSelection(ctx, project.Dependencies, index)
 	if err != nil {
-		return "", fmt.Errorf("failed version selection: %w", err)
+		return nil, fmt.Errorf("failed version selection: %w", err)
 	}
 
-	pythonPath := &strings.Builder{}
-	for i, d := range list {
+	var paths []string
+	for _, d := range list {
 		p, err := index.FindPackage(ctx, d.Name, d.Version)
 		if err != nil {
-			return "", fmt.Errorf("failed to find package after version selection: %w", err)
+			return nil, fmt.Errorf("failed to find package after version selection: %w", err)
+		}
+		if err := project.checkLock(p, true); err != nil {
+			return nil, err
 		}
 
 		// TODO: This function need to find the package AGAIN? doesn't make sense
 		installationPath, err := p.Install(ctx)
 		if err != nil {
-			return "", fmt.Errorf("installing '%s-%s': %w", d.Name, d.Version, err)
+			return nil, fmt.Errorf("installing '%s-%s': %w", d.Name, d.Version, err)
+		}
+
+		paths = append(paths, installationPath)
+	}
+
+	return paths, nil
+}
+
+// syncProject installs the dependencies of the project. If venv is true a virtual
+// environment is also created.
+func syncProject(ctx context.Context, venv bool) error {
+	paths, err := installDependencies(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := linkScripts(paths); err != nil {
+		return err
+	}
+
+	if !venv {
+		return nil
+	}
+
+	dir, err := projectVenv()
+	if err != nil {
+		return err
+	}
+
+	return syncVenv(dir, paths)
+}
+
+func buildPythonPath(ctx context.Context) (string, error) {
+	paths, err := installDependencies(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(paths, string(os.PathListSeparator)), nil
+}
+
+// linkScripts populates the bin directory of the project with links to the
+// scripts of every installed package. Scripts of packages earlier in the
+// list take precedence.
+func linkScripts(paths []string) (string, error) {
+	ropefilePath, err := FindRopefile()
+	if err != nil {
+		return "", err
+	}
+
+	// The directory is recreated to remove scripts of removed dependencies.
+	bin, err := filepath.Abs(filepath.Join(filepath.Dir(ropefilePath), "ropedir", "bin"))
+	if err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(bin); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(bin, 0777); err != nil {
+		return "", err
+	}
+
+	for _, path := range paths {
+		scripts := scriptsPath(path)
+		infos, err := ioutil.ReadDir(scripts)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return "", err
 		}
 
-		if i > 0 {
-			pythonPath.WriteRune(os.PathListSeparator)
+		for _, info := range infos {
+			target, err := filepath.Abs(filepath.Join(scripts, info.Name()))
+			if err != nil {
+				return "", err
+			}
+			if err := os.Symlink(target, filepath.Join(bin, info.Name())); err != nil && !errors.Is(err, os.ErrExist) {
+				return "", err
+			}
 		}
-		pythonPath.WriteString(installationPath)
 	}
 
-	return pythonPath.String(), nil
+	return bin, nil
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLinkScripts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rope-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile("rope.json", []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, name := range []string{"a-1.0-py3-none-any", "b-1.0-py3-none-any"} {
+		scheme := newInstallScheme(filepath.Join("ropedir", installVersion, name), name[:1])
+		if err := os.MkdirAll(scheme.Scripts, 0777); err != nil {
+			t.Fatal(err)
+		}
+		for _, script := range []string{"shared", name[:1]} {
+			if err := ioutil.WriteFile(filepath.Join(scheme.Scripts, script), []byte(name), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		paths = append(paths, scheme.Purelib)
+	}
+
+	// A script of a removed dependency.
+	if err := os.MkdirAll(filepath.Join("ropedir", "bin"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join("ropedir", "bin", "removed"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	bin, err := linkScripts(paths)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for script, expected := range map[string]string{
+		"a":      "a-1.0-py3-none-any",
+		"b":      "b-1.0-py3-none-any",
+		"shared": "a-1.0-py3-none-any",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(bin, script))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", script, err)
+		}
+		if string(b) != expected {
+			t.Fatalf("%s: unexpected script, got: %s, want: %s", script, b, expected)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(bin, "removed")); !os.IsNotExist(err) {
+		t.Fatalf("expected script of removed dependency to be removed")
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"hash"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// recordEntry is a single row in the RECORD file of a wheel.
+// https://www.python.org/dev/peps/pep-0376/#record
+// https://www.python.org/dev/peps/pep-0427/#the-dist-info-directory
+type recordEntry struct {
+	Path      string
+	Algorithm string
+	Digest    []byte
+	// Size is -1 when not recorded.
+	Size int64
+}
+
+// parseRecord parses a RECORD file into entries indexed by path.
+func parseRecord(r io.Reader) (map[string]recordEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+
+	entries := make(map[string]recordEntry)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("parsing RECORD: %w", err)
+		}
+
+		entry := recordEntry{Path: row[0], Size: -1}
+		if row[1] != "" {
+			sep := strings.Index(row[1], "=")
+			if sep < 0 {
+				return nil, fmt.Errorf("parsing RECORD: invalid hash for '%s': '%s'", row[0], row[1])
+			}
+			entry.Algorithm = row[1][:sep]
+			// The digest is encoded using urlsafe base64 without padding.
+			entry.Digest, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(row[1][sep+1:], "="))
+			if err != nil {
+				return nil, fmt.Errorf("parsing RECORD: invalid hash for '%s': %w", row[0], err)
+			}
+		}
+		if row[2] != "" {
+			entry.Size, err = strconv.ParseInt(row[2], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing RECORD: invalid size for '%s': %w", row[0], err)
+			}
+		}
+
+		entries[entry.Path] = entry
+	}
+
+	return entries, nil
+}
+
+// unhashed returns true if the file is allowed to be listed in RECORD
+// without a hash, i.e. RECORD itself and its signatures.
+func (e recordEntry) unhashed() bool {
+	switch path.Base(e.Path) {
+	case "RECORD", "RECORD.jws", "RECORD.p7s":
+		return strings.HasSuffix(path.Dir(e.Path), ".dist-info")
+	default:
+		return false
+	}
+}
+
+// newVerifier returns a verifier of the contents of the file.
+func (e recordEntry) newVerifier() (*recordVerifier, error) {
+	var h hash.Hash
+	switch e.Algorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha384":
+		h = sha512.New384()
+	case "sha512":
+		h = sha512.New()
+	case "":
+		if !e.unhashed() {
+			return nil, fmt.Errorf("'%s' is missing a hash in RECORD", e.Path)
+		}
+	default:
+		// Algorithms weaker than sha256 are not allowed by PEP 427.
+		return nil, fmt.Errorf("unsupported hash algorithm for '%s' in RECORD: '%s'", e.Path, e.Algorithm)
+	}
+
+	return &recordVerifier{entry: e, hash: h}, nil
+}
+
+// recordVerifier hashes and counts everything written to it.
+type recordVerifier struct {
+	entry recordEntry
+	hash  hash.Hash
+	size  int64
+}
+
+func (v *recordVerifier) Write(b []byte) (int, error) {
+	v.size += int64(len(b))
+	if v.hash != nil {
+		v.hash.Write(b)
+	}
+	return len(b), nil
+}
+
+// Verify returns an error if the written contents do not match RECORD.
+func (v *recordVerifier) Verify() error {
+	if v.hash != nil {
+		if sum := v.hash.Sum(nil); !bytes.Equal(sum, v.entry.Digest) {
+			return fmt.Errorf("'%s' does not match RECORD, got: %s=%s, expected: %s=%s",
+				v.entry.Path,
+				v.entry.Algorithm, base64.RawURLEncoding.EncodeToString(sum),
+				v.entry.Algorithm, base64.RawURLEncoding.EncodeToString(v.entry.Digest),
+			)
+		}
+	}
+
+	if v.entry.Size >= 0 && v.size != v.entry.Size {
+		return fmt.Errorf("'%s' does not match RECORD, got size: %d, expected size: %d", v.entry.Path, v.size, v.entry.Size)
+	}
+
+	return nil
+}
+
+// findDistInfo finds the file with the given name in the top-level
+// .dist-info directory of the wheel.
+func findDistInfo(files []*zip.File, name string) *zip.File {
+	for _, file := range files {
+		dir, base := path.Split(file.Name)
+		if base == name && strings.Count(dir, "/") == 1 && strings.HasSuffix(dir, ".dist-info/") {
+			return file
+		}
+	}
+
+	return nil
+}
+
+// readRecord reads the RECORD file of the wheel.
+func readRecord(r *zip.Reader) (map[string]recordEntry, error) {
+	file := findDistInfo(r.File, "RECORD")
+	if file == nil {
+		return nil, fmt.Errorf("RECORD file not found in .whl")
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseRecord(f)
+}
+
+// verifyFile reads the file in the wheel and verifies it against RECORD.
+func verifyFile(file *zip.File, record map[string]recordEntry, dst io.Writer) error {
+	entry, ok := record[file.Name]
+	if !ok {
+		return fmt.Errorf("'%s' is not listed in RECORD", file.Name)
+	}
+
+	verifier, err := entry.newVerifier()
+	if err != nil {
+		return err
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(io.MultiWriter(dst, verifier), f); err != nil {
+		return err
+	}
+
+	return verifier.Verify()
+}
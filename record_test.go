@@ -0,0 +1,147 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRecord(t *testing.T) {
+	record, err := parseRecord(strings.NewReader(`example/__init__.py,sha256=47DEQpj8HBSa-_TImW-5JCeuQeRkm5NMpJWZG3hSuFU,0
+"example/with,comma.py",sha256=47DEQpj8HBSa-_TImW-5JCeuQeRkm5NMpJWZG3hSuFU,0
+example-1.0.dist-info/RECORD,,
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(record) != 3 {
+		t.Fatalf("unexpected number of entries, got: %d, want: 3", len(record))
+	}
+	if e := record["example/with,comma.py"]; e.Algorithm != "sha256" || len(e.Digest) != 32 || e.Size != 0 {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	if e := record["example-1.0.dist-info/RECORD"]; !e.unhashed() || e.Size != -1 {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+
+	if _, err := parseRecord(strings.NewReader("example/__init__.py,sha256,0\n")); err == nil {
+		t.Fatalf("expected error for invalid hash")
+	}
+}
+
+func TestWheelExtractVerifiesRecord(t *testing.T) {
+	valid := []testFile{
+		{"example/__init__.py", []byte("import example.core\n")},
+		{"example-1.0.dist-info/METADATA", []byte("Name: example\n")},
+	}
+
+	testCases := []struct {
+		name  string
+		wheel func(t *testing.T) []byte
+		err   string
+	}{
+		{
+			name: "valid",
+			wheel: func(t *testing.T) []byte {
+				return buildTestWheel(t, valid)
+			},
+		},
+		{
+			name: "tampered",
+			wheel: func(t *testing.T) []byte {
+				return replaceInZip(t, buildTestWheel(t, valid), "example/__init__.py", []byte("import os\n"))
+			},
+			err: "'example/__init__.py' does not match RECORD, got: sha256=",
+		},
+		{
+			name: "unlisted",
+			wheel: func(t *testing.T) []byte {
+				return replaceInZip(t, buildTestWheel(t, valid), "example/injected.py", []byte("import os\n"))
+			},
+			err: "'example/injected.py' is not listed in RECORD",
+		},
+		{
+			name: "traversal",
+			wheel: func(t *testing.T) []byte {
+				return buildTestWheel(t, append(valid, testFile{"../../evil.py", []byte("import os\n")}))
+			},
+			err: "unsafe archive member '../../evil.py': path traversal",
+		},
+		{
+			name: "missing record",
+			wheel: func(t *testing.T) []byte {
+				return replaceInZip(t, buildTestWheel(t, valid), "example-1.0.dist-info/RECORD", nil)
+			},
+			err: "RECORD file not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "rope-test-*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			whlPath := filepath.Join(dir, "example-1.0-py3-none-any.whl")
+			if err := ioutil.WriteFile(whlPath, tc.wheel(t), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			whl := &Wheel{Path: whlPath}
+			err = whl.extract(newInstallScheme(filepath.Join(dir, "install"), "example"))
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if _, err := os.Stat(filepath.Join(dir, "install", "site-packages", "example", "__init__.py")); err != nil {
+					t.Fatalf("expected file to be installed: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("unexpected error, got: %v, want: %s", err, tc.err)
+			}
+		})
+	}
+}
+
+// replaceInZip replaces the file identified by name in the archive. The file
+// is added if it does not exist and removed if data is nil.
+func replaceInZip(t *testing.T, archive []byte, name string, data []byte) []byte {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	zw := zip.NewWriter(out)
+	for _, f := range zr.File {
+		if f.Name == name {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, _ := zw.Create(f.Name)
+		w.Write(b)
+	}
+	if data != nil {
+		w, _ := zw.Create(name)
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return out.Bytes()
+}
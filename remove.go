@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// remove removes one or more direct dependencies from the project and
+// recomputes the minimal requirement list. Packages that are only required
+// transitively can not be removed and results in an error describing which
+// packages still require it.
+func remove(timeout time.Duration, packages []string) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	project, err := ReadRopefile()
+	if err != nil {
+		return err
+	}
+
+	index, err := project.PackageIndex()
+	if err != nil {
+		return err
+	}
+
+	list, err := removeDependencies(ctx, project, index, packages)
+	if err != nil {
+		return err
+	}
+
+	project.updateLock(list, nil)
+	return WriteRopefile(project, "")
+}
+
+// removeDependencies removes the packages from the direct dependencies of the
+// project and replaces them with the minimal requirement list. The resulting
+// build list is returned. Names that are neither direct dependencies nor
+// part of the build list are rejected.
+func removeDependencies(ctx context.Context, project *Project, index PackageIndex, packages []string) ([]Dependency, error) {
+	// Packages that are not direct dependencies must be part of the build
+	// list before the removal.
+	dependencies := append([]Dependency{}, project.Dependencies...)
+	var previous []Dependency
+	inList := func(list []Dependency, name string) bool {
+		for _, d := range list {
+			if d.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Remove all direct dependencies first. Remaining packages are checked
+	// against the resulting build list since they may only have been required
+	// by one of the removed dependencies.
+	var transitive []string
+	for _, p := range packages {
+		name := NormalizePackageName(p)
+
+		found := false
+		for i, d := range project.Dependencies {
+			if d.Name == name {
+				project.Dependencies = append(project.Dependencies[:i], project.Dependencies[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		if previous == nil {
+			var err error
+			previous, _, err = MinimalVersionSelection(ctx, dependencies, index)
+			if err != nil {
+				return nil, fmt.Errorf("failed version selection: %w", err)
+			}
+		}
+		if !inList(previous, name) {
+			return nil, fmt.Errorf("'%s' is not a dependency", name)
+		}
+		transitive = append(transitive, name)
+	}
+
+	list, minimalRequirements, err := MinimalVersionSelection(ctx, project.Dependencies, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed version selection: %w", err)
+	}
+
+	for _, name := range transitive {
+		if !inList(list, name) {
+			// Only required by one of the removed dependencies.
+			continue
+		}
+
+		graph, err := NewGraph(ctx, project.Dependencies, list, index)
+		if err != nil {
+			return nil, err
+		}
+
+		var dependants []string
+		for _, d := range graph.Dependants(name) {
+			dependants = append(dependants, fmt.Sprintf("%s-%s", d.Name, d.Version))
+		}
+
+		return nil, fmt.Errorf("'%s' is a transitive dependency and can not be removed, required by: %s", name, strings.Join(dependants, ", "))
+	}
+
+	project.Dependencies = minimalRequirements
+	return list, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/AlexanderEkdahl/rope/version"
+)
+
+func TestRemoveDependencies(t *testing.T) {
+	index := &testPackageIndex{
+		map[string][]testPackage{
+			"a": {{name: "a", version: version.MustParse("1.0"), dependencies: []Dependency{{Name: "c", Version: version.MustParse("1.0")}}}},
+			"b": {{name: "b", version: version.MustParse("1.0"), dependencies: []Dependency{{Name: "c", Version: version.MustParse("1.0")}}}},
+			"c": {{name: "c", version: version.MustParse("1.0")}},
+		},
+	}
+	newProject := func() *Project {
+		return &Project{Dependencies: []Dependency{
+			{Name: "a", Version: version.MustParse("1.0")},
+			{Name: "b", Version: version.MustParse("1.0")},
+		}}
+	}
+
+	tests := []struct {
+		packages []string
+		want     []string
+		err      string
+	}{
+		{[]string{"A"}, []string{"b"}, ""},
+		// Dependencies only required by removed dependencies are removed.
+		{[]string{"a", "b", "c"}, nil, ""},
+		{[]string{"c"}, nil, "'c' is a transitive dependency and can not be removed, required by: a-1.0, b-1.0"},
+		{[]string{"notapackage"}, nil, "'notapackage' is not a dependency"},
+	}
+
+	for _, test := range tests {
+		project := newProject()
+		_, err := removeDependencies(context.Background(), project, index, test.packages)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Fatalf("%v: got: %v, want: %s", test.packages, err, test.err)
+			}
+			if len(project.Dependencies) != 2 {
+				t.Fatalf("%v: project modified: %v", test.packages, project.Dependencies)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", test.packages, err)
+		}
+
+		var got []string
+		for _, d := range project.Dependencies {
+			got = append(got, d.Name)
+		}
+		if len(got) != len(test.want) || (len(got) > 0 && got[0] != test.want[0]) {
+			t.Fatalf("%v: got: %v, want: %v", test.packages, got, test.want)
+		}
+	}
+}
@@ -4,26 +4,33 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 )
 
 // ExportRequirements exports all of the versions found by minimal
-// version selection in a format that can be consumed by pip.
-func ExportRequirements(ctx context.Context, output io.Writer) error {
+// version selection in a format that can be consumed by pip. Requirements
+// not satisfied by the selected versions are reported to stderr and cause
+// the export to fail if strict is true.
+func ExportRequirements(ctx context.Context, output io.Writer, strict bool) error {
 	project, err := ReadRopefile()
 	if err != nil {
 		return err
 	}
 
-	index := &Index{
-		url: DefaultIndex,
+	index, err := project.PackageIndex()
+	if err != nil {
+		return err
 	}
 	list, _, err := MinimalVersionSelection(ctx, project.Dependencies, index)
 	if err != nil {
 		return fmt.Errorf("failed version selection: %w", err)
 	}
+	if err := checkConflicts(ctx, os.Stderr, project.Dependencies, list, index, strict); err != nil {
+		return err
+	}
 
 	for _, v := range list {
-		fmt.Fprintf(output, "%s==%s\n", v.Name, v.Version)
+		fmt.Fprintf(output, "%s%s==%s\n", v.Name, formatExtras(v.Extras), v.Version)
 	}
 
 	return nil
@@ -6,6 +6,8 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -54,6 +56,13 @@ type Sdist struct {
 
 	// url is only set when the package was found in a remote package repository.
 	url string
+	// hashes maps hash algorithms to the hex encoded digests of the archive as
+	// provided by the package repository.
+	hashes         map[string]string
+	requiresPython string
+	yanked         bool
+	// index is the URL of the package repository.
+	index string
 
 	// Wheel built from source distribituion
 	wheel *Wheel
@@ -66,7 +75,7 @@ func (s *Sdist) Name() string { return s.name }
 func (s *Sdist) Version() version.Version { return s.version }
 
 // Dependencies returns the transitive dependencies of this package.
-func (s *Sdist) Dependencies() []Dependency {
+func (s *Sdist) Dependencies(extras []string) []Dependency {
 	return nil
 }
 
@@ -91,7 +100,13 @@ const setuptoolsShim = `import sys, setuptools, tokenize; sys.argv[0] = 'setup.p
 func (s *Sdist) convert(ctx context.Context) error {
 	fmt.Println("converting sdist:", s.filename)
 
-	body, err := s.fetch(ctx)
+	archive, sum, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive)
+
+	body, err := os.Open(archive)
 	if err != nil {
 		return err
 	}
@@ -159,6 +174,15 @@ func (s *Sdist) convert(ctx context.Context) error {
 	}
 
 	whl.Path = matches[0]
+	whl.Index = s.index
+	whl.Source = &LockedArtifact{
+		Name:     s.name,
+		Version:  s.version.String(),
+		Filename: s.filename,
+		URL:      redactURL(s.url),
+		Sha256:   hex.EncodeToString(sum),
+		Index:    s.index,
+	}
 	if err := whl.extractDependencies(ctx); err != nil {
 		return fmt.Errorf("failed extracting dependencies from built wheel: %w", err)
 	}
@@ -193,12 +217,16 @@ func (s *Sdist) untar(body io.Reader, tmp string) error {
 		case tar.TypeDir:
 			// Some tar files are somehow built without directory entries so
 			// these can not be relied upon.
-		case tar.TypeReg:
+		case tar.TypeReg, tar.TypeRegA:
+			target, err := safeJoin(tmp, hdr.Name)
+			if err != nil {
+				return err
+			}
 			// TODO: Final directory should be created with 0500
-			if err := os.MkdirAll(filepath.Dir(filepath.Join(tmp, hdr.Name)), 0777); err != nil {
+			if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
 				return err
 			}
-			out, err := os.Create(filepath.Join(tmp, hdr.Name))
+			out, err := os.Create(target)
 			if err != nil {
 				return err
 			}
@@ -209,6 +237,37 @@ func (s *Sdist) untar(body io.Reader, tmp string) error {
 			if err := out.Close(); err != nil {
 				return err
 			}
+		case tar.TypeSymlink:
+			target, err := safeSymlink(tmp, hdr.Name, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			// The name of the hard link target is relative to the root of the archive.
+			oldname, err := safeJoin(tmp, hdr.Linkname)
+			if err != nil {
+				return &UnsafePathError{Member: hdr.Name, Reason: fmt.Sprintf("hard link to '%s' escapes the destination", hdr.Linkname)}
+			}
+			target, err := safeJoin(tmp, hdr.Name)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+				return err
+			}
+			if err := os.Link(oldname, target); err != nil {
+				return err
+			}
+		case tar.TypeChar, tar.TypeBlock:
+			return &UnsafePathError{Member: hdr.Name, Reason: "device file"}
+		case tar.TypeFifo:
+			return &UnsafePathError{Member: hdr.Name, Reason: "special file"}
 		}
 	}
 
@@ -228,30 +287,29 @@ func (s *Sdist) unzip(body io.Reader, tmp string) error {
 	}
 
 	for _, file := range r.File {
-		f, err := file.Open()
-		if err != nil {
-			return err
-		}
-
 		if file.FileInfo().IsDir() {
 			continue
 		}
-
-		target := filepath.Join(tmp, file.Name)
-		if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+		if err := checkMode(file.Name, file.Mode()); err != nil {
 			return err
 		}
 
-		dst, err := os.Create(target)
+		if file.Mode()&os.ModeSymlink != 0 {
+			if err := extractZipSymlink(file, tmp); err != nil {
+				return err
+			}
+			continue
+		}
+
+		target, err := safeJoin(tmp, file.Name)
 		if err != nil {
 			return err
 		}
-
-		if _, err := io.Copy(dst, f); err != nil {
+		if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
 			return err
 		}
 
-		if err := f.Close(); err != nil {
+		if err := extractZipFile(file, target); err != nil {
 			return err
 		}
 	}
@@ -259,6 +317,51 @@ func (s *Sdist) unzip(body io.Reader, tmp string) error {
 	return nil
 }
 
+func extractZipFile(file *zip.File, target string) error {
+	f, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dst, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, f); err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
+
+// extractZipSymlink creates the symbolic link stored in the archive. The
+// contents of the member is the target of the link.
+func extractZipSymlink(file *zip.File, root string) error {
+	f, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	linkname, err := ioutil.ReadAll(io.LimitReader(f, 4096))
+	if err != nil {
+		return err
+	}
+
+	target, err := safeSymlink(root, file.Name, string(linkname))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+		return err
+	}
+
+	return os.Symlink(string(linkname), target)
+}
+
 // Install extracts the source distribution and invokes the Python interpreter to
 // run a shim around setuptools to create a Python wheel package. If successful
 // the wheel is then installed.
@@ -272,22 +375,46 @@ func (s *Sdist) Install(ctx context.Context) (string, error) {
 	return s.wheel.Install(ctx)
 }
 
-func (s *Sdist) fetch(ctx context.Context) (io.ReadCloser, error) {
+// fetch downloads the source distribution to a temporary file and verifies
+// it against the sha256 digest provided by the package repository. The path
+// and sha256 digest of the file is returned.
+func (s *Sdist) fetch(ctx context.Context) (string, []byte, error) {
 	r, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 
-	res, err := http.DefaultClient.Do(r)
+	res, err := client.Do(r)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
+	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed HTTP request: %s", res.Status)
+		return "", nil, fmt.Errorf("failed HTTP request: %s", res.Status)
+	}
+
+	file, err := ioutil.TempFile("", fmt.Sprintf("%s-*", s.filename))
+	if err != nil {
+		return "", nil, err
 	}
+	defer file.Close()
 
-	// TODO: Verify checksum
+	hash := sha256.New()
+	if _, err := io.Copy(file, io.TeeReader(res.Body, hash)); err != nil {
+		os.Remove(file.Name())
+		return "", nil, err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", nil, fmt.Errorf("closing file after download: %w", err)
+	}
+
+	sum := hash.Sum(nil)
+	if expectedSum := s.hashes["sha256"]; expectedSum != "" && !strings.EqualFold(expectedSum, hex.EncodeToString(sum)) {
+		os.Remove(file.Name())
+		return "", nil, fmt.Errorf("checksum mismatch, got: %x, expected: %s", sum, expectedSum)
+	}
 
-	return res.Body, nil
+	return file.Name(), sum, nil
 }
@@ -1,6 +1,21 @@
 package main
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -16,3 +31,195 @@ func TestParsingSdist(t *testing.T) {
 		t.Fatalf("wrong version, got: %s, expected: %s", sdist.version, "3.0.0")
 	}
 }
+
+type testTarEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	data     string
+}
+
+func buildTestTarGz(t *testing.T, entries []testTarEntry) []byte {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Linkname: e.linkname,
+			Size:     int64(len(e.data)),
+			Mode:     0644,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(e.data))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSdistUntarUnsafe(t *testing.T) {
+	testCases := []struct {
+		name    string
+		entries []testTarEntry
+		member  string
+	}{
+		{
+			name:    "safe",
+			entries: []testTarEntry{{name: "example-1.0/setup.py", typeflag: tar.TypeReg}, {name: "example-1.0/link.py", typeflag: tar.TypeSymlink, linkname: "setup.py"}},
+		},
+		{
+			name:    "traversal",
+			entries: []testTarEntry{{name: "example-1.0/../../evil.py", typeflag: tar.TypeReg, data: "evil"}},
+			member:  "example-1.0/../../evil.py",
+		},
+		{
+			name:    "absolute",
+			entries: []testTarEntry{{name: "/tmp/evil.py", typeflag: tar.TypeReg, data: "evil"}},
+			member:  "/tmp/evil.py",
+		},
+		{
+			name: "escaping symlink",
+			entries: []testTarEntry{
+				{name: "example-1.0/link", typeflag: tar.TypeSymlink, linkname: "../../"},
+				{name: "example-1.0/link/evil.py", typeflag: tar.TypeReg, data: "evil"},
+			},
+			member: "example-1.0/link",
+		},
+		{
+			name:    "escaping hard link",
+			entries: []testTarEntry{{name: "example-1.0/passwd", typeflag: tar.TypeLink, linkname: "../etc/passwd"}},
+			member:  "example-1.0/passwd",
+		},
+		{
+			name:    "device",
+			entries: []testTarEntry{{name: "example-1.0/null", typeflag: tar.TypeChar}},
+			member:  "example-1.0/null",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "rope-test-*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			root := filepath.Join(dir, "a", "b")
+
+			err = (&Sdist{}).untar(bytes.NewReader(buildTestTarGz(t, tc.entries)), root)
+			if tc.member == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var unsafePathErr *UnsafePathError
+			if !errors.As(err, &unsafePathErr) {
+				t.Fatalf("expected UnsafePathError, got: %v", err)
+			}
+			if unsafePathErr.Member != tc.member {
+				t.Fatalf("unexpected member, got: %s, want: %s", unsafePathErr.Member, tc.member)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "evil.py")); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("expected file outside of destination to not exist")
+			}
+		})
+	}
+}
+
+func TestSdistUnzipUnsafe(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header zip.FileHeader
+		data   string
+		member string
+	}{
+		{name: "safe", header: zip.FileHeader{Name: "example-1.0/setup.py"}},
+		{name: "traversal", header: zip.FileHeader{Name: "../evil.py"}, data: "evil", member: "../evil.py"},
+		{name: "windows traversal", header: zip.FileHeader{Name: "..\\evil.py"}, data: "evil", member: "..\\evil.py"},
+		{name: "escaping symlink", header: zip.FileHeader{Name: "example-1.0/link"}, data: "../../../etc", member: "example-1.0/link"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "rope-test-*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			root := filepath.Join(dir, "a")
+
+			if tc.name == "escaping symlink" {
+				tc.header.SetMode(os.ModeSymlink | 0777)
+			}
+			buf := &bytes.Buffer{}
+			zw := zip.NewWriter(buf)
+			w, _ := zw.CreateHeader(&tc.header)
+			w.Write([]byte(tc.data))
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			err = (&Sdist{}).unzip(buf, root)
+			if tc.member == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var unsafePathErr *UnsafePathError
+			if !errors.As(err, &unsafePathErr) {
+				t.Fatalf("expected UnsafePathError, got: %v", err)
+			}
+			if unsafePathErr.Member != tc.member {
+				t.Fatalf("unexpected member, got: %s, want: %s", unsafePathErr.Member, tc.member)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "evil.py")); !errors.Is(err, os.ErrNotExist) {
+				t.Fatalf("expected file outside of destination to not exist")
+			}
+		})
+	}
+}
+
+func TestSdistFetchChecksum(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "archive")
+	}))
+	defer server.Close()
+
+	sum := sha256.Sum256([]byte("archive"))
+	for _, test := range []struct {
+		sum string
+		ok  bool
+	}{
+		{"", true},
+		{hex.EncodeToString(sum[:]), true},
+		{strings.Repeat("0", 64), false},
+	} {
+		s := &Sdist{filename: "example-1.0.tar.gz", url: server.URL, hashes: map[string]string{"sha256": test.sum}}
+		path, got, err := s.fetch(context.Background())
+		if !test.ok {
+			if err == nil {
+				os.Remove(path)
+				t.Fatalf("%s: expected checksum mismatch", test.sum)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.sum, err)
+		}
+		os.Remove(path)
+		if !bytes.Equal(got, sum[:]) {
+			t.Fatalf("got: %x, want: %x", got, sum)
+		}
+	}
+}
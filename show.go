@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Show prints the build list selected by minimal version selection as a tree
+// rooted in the direct dependencies of the project. If jsonOutput is true the
+// graph is instead written as JSON.
+func Show(ctx context.Context, output io.Writer, jsonOutput bool) error {
+	project, err := ReadRopefile()
+	if err != nil {
+		return err
+	}
+
+	index, err := project.PackageIndex()
+	if err != nil {
+		return err
+	}
+	list, _, err := MinimalVersionSelection(ctx, project.Dependencies, index)
+	if err != nil {
+		return fmt.Errorf("failed version selection: %w", err)
+	}
+
+	graph, err := NewGraph(ctx, project.Dependencies, list, index)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return writeGraphJSON(output, graph)
+	}
+
+	writeTree(output, graph)
+	return nil
+}
+
+// writeTree writes the graph as a tree. Every package is only expanded the
+// first time it is encountered; later occurrences are marked with (*).
+//
+// 	requests 2.24.0 (requires 2.24.0)
+// 	├── certifi 2020.6.20 (requires 2017.4.17) [mismatch]
+// 	└── urllib3 1.25.10 (requires 1.21.1) [mismatch]
+func writeTree(output io.Writer, g *Graph) {
+	expanded := make(map[string]bool)
+
+	var walk func(d Dependency, prefix, childPrefix string)
+	walk = func(d Dependency, prefix, childPrefix string) {
+		node, ok := g.Nodes[d.Name]
+		if !ok {
+			fmt.Fprintf(output, "%s%s (missing)\n", prefix, d.Name)
+			return
+		}
+
+		line := &strings.Builder{}
+		fmt.Fprintf(line, "%s%s %s (requires %s)", prefix, node.Name, node.Version.Canonical(), requested(d))
+		if node.Unspecified {
+			line.WriteString(" [unspecified]")
+		}
+		if node.Mismatch {
+			line.WriteString(" [mismatch]")
+		}
+
+		edges := g.Edges[d.Name]
+		if expanded[d.Name] && len(edges) > 0 {
+			line.WriteString(" (*)")
+			fmt.Fprintln(output, line.String())
+			return
+		}
+		expanded[d.Name] = true
+		fmt.Fprintln(output, line.String())
+
+		for i, e := range edges {
+			if i == len(edges)-1 {
+				walk(e, childPrefix+"└── ", childPrefix+"    ")
+			} else {
+				walk(e, childPrefix+"├── ", childPrefix+"│   ")
+			}
+		}
+	}
+
+	for _, d := range sortedDependencies(g.Roots) {
+		walk(d, "", "")
+	}
+}
+
+type showPackage struct {
+	Name         string           `json:"name"`
+	Version      string           `json:"version"`
+	Direct       bool             `json:"direct"`
+	Unspecified  bool             `json:"unspecified"`
+	Mismatch     bool             `json:"mismatch"`
+	Dependencies []showDependency `json:"dependencies"`
+}
+
+type showDependency struct {
+	Name string `json:"name"`
+	// Minimum is the minimal version requested by the dependant. Empty when
+	// no version constraint is applied.
+	Minimum string `json:"minimum,omitempty"`
+}
+
+// writeGraphJSON writes every package in the graph along with the minimal
+// version each package requests of its dependencies.
+func writeGraphJSON(output io.Writer, g *Graph) error {
+	direct := make(map[string]bool, len(g.Roots))
+	for _, d := range g.Roots {
+		direct[d.Name] = true
+	}
+
+	packages := make([]showPackage, 0, len(g.Nodes))
+	for name, node := range g.Nodes {
+		dependencies := make([]showDependency, 0, len(g.Edges[name]))
+		for _, d := range g.Edges[name] {
+			sd := showDependency{Name: d.Name}
+			if !d.Version.Unspecified() {
+				sd.Minimum = d.Version.Canonical()
+			}
+			dependencies = append(dependencies, sd)
+		}
+
+		packages = append(packages, showPackage{
+			Name:         node.Name,
+			Version:      node.Version.Canonical(),
+			Direct:       direct[name],
+			Unspecified:  node.Unspecified,
+			Mismatch:     node.Mismatch,
+			Dependencies: dependencies,
+		})
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+
+	enc := json.NewEncoder(output)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	return enc.Encode(struct {
+		Packages []showPackage `json:"packages"`
+	}{packages})
+}
+
+// requested formats the minimal version requested of a dependency.
+func requested(d Dependency) string {
+	if d.Version.Unspecified() {
+		return "any"
+	}
+	return d.Version.Canonical()
+}
+
+func sortedDependencies(dependencies []Dependency) []Dependency {
+	sorted := append([]Dependency{}, dependencies...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
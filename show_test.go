@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/AlexanderEkdahl/rope/version"
+)
+
+func TestWriteTree(t *testing.T) {
+	index := &testPackageIndex{
+		map[string][]testPackage{
+			"a": {
+				{
+					name:    "a",
+					version: version.MustParse("1.0"),
+					dependencies: []Dependency{
+						{Name: "c", Version: version.MustParse("1.1")},
+					},
+				},
+			},
+			"b": {
+				{
+					name:    "b",
+					version: version.MustParse("1.0"),
+					dependencies: []Dependency{
+						{Name: "c", Version: version.MustParse("1.2")},
+						{Name: "d"},
+					},
+				},
+			},
+			"c": {
+				{
+					name:    "c",
+					version: version.MustParse("1.1"),
+				},
+				{
+					name:    "c",
+					version: version.MustParse("1.2"),
+					dependencies: []Dependency{
+						{Name: "d"},
+					},
+				},
+			},
+			"d": {
+				{
+					name:    "d",
+					version: version.MustParse("2.0"),
+				},
+			},
+		},
+	}
+
+	base := []Dependency{
+		{Name: "b", Version: version.MustParse("1.0")},
+		{Name: "a", Version: version.MustParse("1.0")},
+	}
+	ctx := context.Background()
+	list, _, err := MinimalVersionSelection(ctx, base, index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	graph, err := NewGraph(ctx, base, list, index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var sb strings.Builder
+	writeTree(&sb, graph)
+
+	expected := `a 1.0 (requires 1.0)
+└── c 1.2 (requires 1.1)
+    └── d 2.0 (requires any) [unspecified]
+b 1.0 (requires 1.0)
+├── c 1.2 (requires 1.2) (*)
+└── d 2.0 (requires any) [unspecified]
+`
+	if sb.String() != expected {
+		t.Fatalf("unexpected tree, got:\n%s\nwant:\n%s", sb.String(), expected)
+	}
+
+	if dependants := graph.Dependants("d"); len(dependants) != 2 || dependants[0].Name != "b" || dependants[1].Name != "c" {
+		t.Fatalf("unexpected dependants of d: %v", dependants)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Media types of the simple repository API.
+// https://www.python.org/dev/peps/pep-0691/
+const (
+	simpleJSONMediaType = "application/vnd.pypi.simple.v1+json"
+	simpleHTMLMediaType = "application/vnd.pypi.simple.v1+html"
+	simpleAccept        = simpleJSONMediaType + ", " + simpleHTMLMediaType + ";q=0.2, text/html;q=0.01"
+)
+
+// simpleFile is a single distribution file listed by a simple repository or
+// a page of links.
+type simpleFile struct {
+	Filename string
+	// URL is the absolute URL of the file without any fragment.
+	URL string
+	// Hashes maps hash algorithms to hex encoded digests.
+	Hashes         map[string]string
+	RequiresPython string
+	// Yanked is true if the file has been yanked(PEP 592).
+	Yanked bool
+	// Metadata is true if the core metadata of the file is served separately
+	// at <URL>.metadata(PEP 658).
+	Metadata       bool
+	MetadataHashes map[string]string
+}
+
+// parseSimpleJSON parses the JSON serialization of a project page.
+// https://www.python.org/dev/peps/pep-0691/#json-serialization
+func parseSimpleJSON(r io.Reader, base *url.URL) ([]simpleFile, error) {
+	var page struct {
+		Meta struct {
+			APIVersion string `json:"api-version"`
+		} `json:"meta"`
+		Files []struct {
+			Filename       string            `json:"filename"`
+			URL            string            `json:"url"`
+			Hashes         map[string]string `json:"hashes"`
+			RequiresPython string            `json:"requires-python"`
+			// Either a boolean or a string containing the reason.
+			Yanked json.RawMessage `json:"yanked"`
+			// Either a boolean or a dictionary of hashes.
+			DistInfoMetadata json.RawMessage `json:"dist-info-metadata"`
+			CoreMetadata     json.RawMessage `json:"core-metadata"`
+		} `json:"files"`
+	}
+	if err := json.NewDecoder(r).Decode(&page); err != nil {
+		return nil, fmt.Errorf("decoding JSON response: %w", err)
+	}
+
+	if major := strings.SplitN(page.Meta.APIVersion, ".", 2)[0]; major != "1" {
+		return nil, fmt.Errorf("unsupported simple API version: '%s'", page.Meta.APIVersion)
+	}
+
+	files := make([]simpleFile, 0, len(page.Files))
+	for _, f := range page.Files {
+		ref, err := url.Parse(f.URL)
+		if err != nil {
+			return nil, err
+		}
+		u := base.ResolveReference(ref)
+		u.Fragment = ""
+
+		file := simpleFile{
+			Filename:       f.Filename,
+			URL:            u.String(),
+			Hashes:         f.Hashes,
+			RequiresPython: f.RequiresPython,
+			Yanked:         len(f.Yanked) > 0 && string(f.Yanked) != "false" && string(f.Yanked) != "null",
+		}
+
+		// core-metadata supersedes dist-info-metadata(PEP 714).
+		metadata := f.CoreMetadata
+		if len(metadata) == 0 {
+			metadata = f.DistInfoMetadata
+		}
+		var hashes map[string]string
+		if err := json.Unmarshal(metadata, &hashes); err == nil && hashes != nil {
+			file.Metadata = true
+			file.MetadataHashes = hashes
+		} else {
+			file.Metadata = string(metadata) == "true"
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// parseSimpleHTML parses every link in a HTML page. This is both used for the
+// HTML serialization of a project page and for pages of links.
+// https://www.python.org/dev/peps/pep-0503/
+func parseSimpleHTML(r io.Reader, base *url.URL) ([]simpleFile, error) {
+	var files []simpleFile
+
+	dec := xml.NewDecoder(r)
+	// Pages are rarely valid XML.
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+	for {
+		token, err := dec.Token()
+		var syntaxError *xml.SyntaxError
+		if err == io.EOF {
+			break
+		} else if errors.As(err, &syntaxError) && syntaxError.Msg == "unexpected EOF" {
+			// Safe to assume no more links will be found unless index download was
+			// unexpectedly terminated half way through. This is here since pip
+			// seemingly does not care about invalid XML.
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "a" {
+			continue
+		}
+
+		var file simpleFile
+		href := ""
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "href":
+				href = attr.Value
+			case "data-requires-python":
+				file.RequiresPython = attr.Value
+			case "data-yanked":
+				file.Yanked = true
+			case "data-dist-info-metadata", "data-core-metadata":
+				// data-core-metadata supersedes data-dist-info-metadata(PEP 714).
+				if attr.Name.Local == "data-dist-info-metadata" && file.Metadata {
+					continue
+				}
+				file.Metadata = true
+				file.MetadataHashes = parseHashFragment(attr.Value)
+			}
+		}
+
+		ref, err := url.Parse(href)
+		if err != nil {
+			continue
+		}
+		u := base.ResolveReference(ref)
+		file.Hashes = parseHashFragment(u.Fragment)
+		u.Fragment = ""
+		file.URL = u.String()
+		file.Filename = path.Base(u.Path)
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// parseHashFragment parses hashes in the form of <algorithm>=<digest>.
+func parseHashFragment(s string) map[string]string {
+	sep := strings.Index(s, "=")
+	if sep < 0 {
+		return nil
+	}
+
+	return map[string]string{s[:sep]: s[sep+1:]}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AlexanderEkdahl/rope/version"
+)
+
+func TestParseSimpleJSON(t *testing.T) {
+	base, _ := url.Parse("https://example.com/simple/example/")
+	files, err := parseSimpleJSON(strings.NewReader(`{
+		"meta": {"api-version": "1.1"},
+		"name": "example",
+		"files": [
+			{
+				"filename": "example-1.0-py3-none-any.whl",
+				"url": "../../files/example-1.0-py3-none-any.whl",
+				"hashes": {"sha256": "abc"},
+				"requires-python": ">=3.6",
+				"core-metadata": {"sha256": "def"},
+				"yanked": false
+			},
+			{
+				"filename": "example-0.9.tar.gz",
+				"url": "https://files.example.com/example-0.9.tar.gz",
+				"hashes": {},
+				"dist-info-metadata": true,
+				"yanked": "broken release"
+			}
+		]
+	}`), base)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []simpleFile{
+		{
+			Filename:       "example-1.0-py3-none-any.whl",
+			URL:            "https://example.com/files/example-1.0-py3-none-any.whl",
+			Hashes:         map[string]string{"sha256": "abc"},
+			RequiresPython: ">=3.6",
+			Metadata:       true,
+			MetadataHashes: map[string]string{"sha256": "def"},
+		},
+		{
+			Filename: "example-0.9.tar.gz",
+			URL:      "https://files.example.com/example-0.9.tar.gz",
+			Hashes:   map[string]string{},
+			Yanked:   true,
+			Metadata: true,
+		},
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("unexpected files, got: %+v, want: %+v", files, expected)
+	}
+
+	if _, err := parseSimpleJSON(strings.NewReader(`{"meta": {"api-version": "2.0"}, "files": []}`), base); err == nil {
+		t.Fatalf("expected error for unsupported api version")
+	}
+}
+
+func TestParseSimpleHTML(t *testing.T) {
+	base, _ := url.Parse("https://example.com/simple/example/")
+	files, err := parseSimpleHTML(strings.NewReader(`<!DOCTYPE html>
+<html>
+  <head><meta name="pypi:repository-version" content="1.0"><title>Links for example</title></head>
+  <body>
+    <h1>Links for example</h1>
+    <a href="../../files/example-1.0-py3-none-any.whl#sha256=abc" data-requires-python="&gt;=3.6" data-dist-info-metadata="sha256=def">example-1.0-py3-none-any.whl</a><br/>
+    <a href="https://files.example.com/example-0.9.tar.gz" data-yanked="">example-0.9.tar.gz</a><br/>
+  </body>
+</html>`), base)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []simpleFile{
+		{
+			Filename:       "example-1.0-py3-none-any.whl",
+			URL:            "https://example.com/files/example-1.0-py3-none-any.whl",
+			Hashes:         map[string]string{"sha256": "abc"},
+			RequiresPython: ">=3.6",
+			Metadata:       true,
+			MetadataHashes: map[string]string{"sha256": "def"},
+		},
+		{
+			Filename: "example-0.9.tar.gz",
+			URL:      "https://files.example.com/example-0.9.tar.gz",
+			Yanked:   true,
+		},
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("unexpected files, got: %+v, want: %+v", files, expected)
+	}
+}
+
+func TestIndexContentNegotiation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept"), simpleJSONMediaType) {
+			t.Errorf("expected JSON to be accepted, got: %s", r.Header.Get("Accept"))
+		}
+
+		switch r.URL.Path {
+		case "/json/example/":
+			w.Header().Set("Content-Type", simpleJSONMediaType)
+			fmt.Fprint(w, `{"meta": {"api-version": "1.0"}, "files": [
+				{"filename": "example-1.0.tar.gz", "url": "example-1.0.tar.gz", "hashes": {"sha256": "abc"}},
+				{"filename": "example-1.1.tar.gz", "url": "example-1.1.tar.gz", "hashes": {}, "yanked": true}
+			]}`)
+		case "/html/example/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<a href="example-1.0.tar.gz#sha256=abc">example-1.0.tar.gz</a>`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	oldCache := cache
+	defer func() {
+		cache = oldCache
+	}()
+	cache = &Cache{Temporary: true}
+	defer cache.Close()
+
+	for _, path := range []string{"json", "html"} {
+		index := &Index{url: server.URL + "/" + path}
+		p, err := index.FindPackage(context.Background(), "example", version.Version{})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+
+		sdist := p.(*Sdist)
+		if sdist.version.String() != "1.0" || sdist.hashes["sha256"] != "abc" {
+			t.Fatalf("%s: unexpected package: %+v", path, sdist)
+		}
+		if sdist.url != fmt.Sprintf("%s/%s/example/example-1.0.tar.gz", server.URL, path) {
+			t.Fatalf("%s: unexpected url: %s", path, sdist.url)
+		}
+
+		// Yanked files are only selected when explicitly requested.
+		if path == "json" {
+			p, err := index.FindPackage(context.Background(), "example", version.MustParse("1.1"))
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", path, err)
+			}
+			if !p.(*Sdist).yanked {
+				t.Fatalf("%s: expected yanked package", path)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+/*
+
+Installed wheels are kept in a content-addressed store shared between every
+project. Entries are keyed by the sha256 digest of the wheel and the
+interpreter the wheel was installed for as scripts refer to the interpreter.
+Every file and directory in the store is write-protected.
+
+<cache> / store / <installVersion> / <key>        installed wheel
+                                   / <key>.lock   held while populating <key>
+
+Projects use views of the store in ./ropedir built using hardlinks. Every view
+records the key of the store entry it was built from(see viewKeyFile) as the
+view is named after the wheel filename which does not identify its content.
+
+*/
+
+// storeKey returns the key of the wheel with the provided sha256 digest
+// installed for interpreter.
+func storeKey(wheelSum []byte, interpreter string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%x\n%s\n", wheelSum, interpreter)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) storePath() string {
+	return filepath.Join(c.Path, "store", installVersion)
+}
+
+// Install returns the path to the store entry identified by key. If the
+// entry does not exist it is populated by calling populate with a temporary
+// directory which is atomically moved into place. Concurrent calls, also
+// across processes, populate the entry once.
+func (c *Cache) Install(key string, populate func(dir string) error) (string, error) {
+	c.once.Do(c.setup)
+	if c.err != nil {
+		return "", c.err
+	}
+
+	dir := filepath.Join(c.storePath(), key)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	if err := os.MkdirAll(c.storePath(), 0777); err != nil {
+		return "", fmt.Errorf("creating store directory: %w", err)
+	}
+
+	lock, err := lockFile(dir + ".lock")
+	if err != nil {
+		return "", fmt.Errorf("locking store entry: %w", err)
+	}
+	defer lock.Unlock()
+
+	// The entry may have been populated while waiting for the lock.
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	tmp, err := ioutil.TempDir(c.storePath(), key+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer removeAll(tmp)
+
+	if err := populate(tmp); err != nil {
+		return "", err
+	}
+	if err := writeProtect(tmp); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return "", fmt.Errorf("moving item to store: %w", err)
+	}
+
+	return dir, nil
+}
+
+// writeProtect removes the write permission of every file and directory
+// within path.
+func writeProtect(path string) error {
+	// Directories are protected after their contents.
+	var dirs []string
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			dirs = append(dirs, p)
+		case info.Mode().IsRegular():
+			return os.Chmod(p, info.Mode().Perm()&^0222)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i], 0555); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeAll removes path and any children it contains including
+// write-protected directories.
+func removeAll(path string) error {
+	filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			os.Chmod(p, 0777)
+		}
+		return nil
+	})
+
+	return os.RemoveAll(path)
+}
+
+// viewKeyFile is the file in a view recording the key of the store entry the
+// view was built from.
+const viewKeyFile = ".rope-store-key"
+
+// viewKey returns the key of the store entry the view at prefix was built
+// from. The empty string is returned if the view does not exist or was
+// built by an earlier version of rope.
+func viewKey(prefix string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(prefix, viewKeyFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// linkView builds a view of the store entry at dir in prefix using
+// hardlinks. The view is built in a temporary directory and atomically moved
+// into place. An existing view of another store entry is replaced.
+func linkView(dir, prefix string) error {
+	if err := os.MkdirAll(filepath.Dir(prefix), 0777); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempDir(filepath.Dir(prefix), filepath.Base(prefix)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := linkTree(dir, tmp); err != nil {
+		return err
+	}
+	key := filepath.Base(dir)
+	if err := ioutil.WriteFile(filepath.Join(tmp, viewKeyFile), []byte(key), 0444); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, prefix); err != nil {
+		// Another process may have built the view concurrently.
+		if existing, keyErr := viewKey(prefix); keyErr == nil && existing == key {
+			return nil
+		} else if _, statErr := os.Stat(prefix); statErr != nil {
+			return err
+		}
+
+		// The view was built from another store entry, e.g. a wheel with the
+		// same filename from another index or rebuilt from source.
+		if err := removeAll(prefix); err != nil {
+			return fmt.Errorf("removing outdated view: %w", err)
+		}
+		return os.Rename(tmp, prefix)
+	}
+
+	return nil
+}
+
+// fileSum returns the sha256 digest of the file at path.
+func fileSum(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCacheInstallConcurrent(t *testing.T) {
+	cache := &Cache{Temporary: true}
+	defer cache.Close()
+
+	var populated int32
+	populate := func(dir string) error {
+		atomic.AddInt32(&populated, 1)
+		return writeFile(filepath.Join(dir, "site-packages", "example", "__init__.py"), []byte("example"), 0444)
+	}
+
+	key := storeKey([]byte{1, 2, 3}, "/usr/bin/python")
+	var wg sync.WaitGroup
+	dirs := make([]string, 16)
+	errs := make([]error, 16)
+	for i := range dirs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dirs[i], errs[i] = cache.Install(key, populate)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range dirs {
+		if errs[i] != nil {
+			t.Fatalf("unexpected error: %v", errs[i])
+		}
+		if dirs[i] != dirs[0] {
+			t.Fatalf("unexpected store entry, got: %s, want: %s", dirs[i], dirs[0])
+		}
+	}
+	if populated != 1 {
+		t.Fatalf("expected store entry to be populated once, got: %d", populated)
+	}
+
+	// Store entries are write-protected.
+	info, err := os.Stat(filepath.Join(dirs[0], "site-packages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0222 != 0 {
+		t.Fatalf("expected store entry to be write-protected, got: %s", info.Mode())
+	}
+
+	// Views share files with the store.
+	view := filepath.Join(cache.Path, "project", "ropedir", "example")
+	if err := linkView(dirs[0], view); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stored, err := os.Stat(filepath.Join(dirs[0], "site-packages", "example", "__init__.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	linked, err := os.Stat(filepath.Join(view, "site-packages", "example", "__init__.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(stored, linked) {
+		t.Fatalf("expected view to be hardlinked to the store")
+	}
+
+	// Failed installations do not leave an entry in the store.
+	if _, err := cache.Install(storeKey([]byte{4}, "/usr/bin/python"), func(dir string) error {
+		return os.ErrInvalid
+	}); err == nil {
+		t.Fatalf("expected error")
+	}
+	entries, err := ioutil.ReadDir(cache.storePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.IsDir() && e.Name() != key {
+			t.Fatalf("unexpected store entry: %s", e.Name())
+		}
+	}
+
+	if err := cache.Clean(); err != nil {
+		t.Fatalf("unexpected error cleaning store: %v", err)
+	}
+}
+
+func TestLinkViewReplacesOutdatedView(t *testing.T) {
+	cache := &Cache{Temporary: true}
+	defer cache.Close()
+
+	install := func(content string) string {
+		dir, err := cache.Install(storeKey([]byte(content), "/usr/bin/python"), func(dir string) error {
+			return writeFile(filepath.Join(dir, "site-packages", "example.py"), []byte(content), 0444)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return dir
+	}
+
+	view := filepath.Join(cache.Path, "project", "ropedir", "example-1.0-py3-none-any")
+	for _, content := range []string{"original", "rebuilt", "rebuilt"} {
+		dir := install(content)
+		if err := linkView(dir, view); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		key, err := viewKey(view)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != filepath.Base(dir) {
+			t.Fatalf("got: %s, want: %s", key, filepath.Base(dir))
+		}
+		b, err := ioutil.ReadFile(filepath.Join(view, "site-packages", "example.py"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Fatalf("got: %s, want: %s", b, content)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+/*
+
+Every HTTP request made by rope uses the same client which is configured
+through the following environment variables:
+
+	ROPE_HTTP_RETRIES   number of times a failed request is retried (default: 3)
+	ROPE_HTTP_TIMEOUT   time to wait for a response from a server (default: 30s)
+	ROPE_CA_BUNDLE      path to a PEM file with additional certificate authorities
+	ROPE_OFFLINE        only use cached responses(same as --offline)
+	HTTPS_PROXY         proxy used for HTTPS requests
+	HTTP_PROXY          proxy used for HTTP requests
+	NO_PROXY            hosts that should not be proxied
+
+*/
+
+const (
+	defaultRetries = 3
+	defaultTimeout = 30 * time.Second
+	// maxRetryAfter limits how long a server can ask rope to wait.
+	maxRetryAfter = 2 * time.Minute
+)
+
+// newClient returns the HTTP client used for every request. Requests are
+// authenticated using auth and responses to metadata requests are cached in
+// cache. If offline is true every request must be served from the cache.
+func newClient(auth *Auth, cache *Cache, offline bool) (*http.Client, error) {
+	retries := defaultRetries
+	if s := os.Getenv("ROPE_HTTP_RETRIES"); s != "" {
+		var err error
+		retries, err = strconv.Atoi(s)
+		if err != nil || retries < 0 {
+			return nil, fmt.Errorf("invalid ROPE_HTTP_RETRIES: '%s'", s)
+		}
+	}
+
+	timeout := defaultTimeout
+	if s := os.Getenv("ROPE_HTTP_TIMEOUT"); s != "" {
+		var err error
+		timeout, err = time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ROPE_HTTP_TIMEOUT: %w", err)
+		}
+	}
+
+	if s := os.Getenv("ROPE_OFFLINE"); s != "" {
+		envOffline, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ROPE_OFFLINE: '%s'", s)
+		}
+		offline = offline || envOffline
+	}
+
+	tlsConfig := &tls.Config{}
+	if path := os.Getenv("ROPE_CA_BUNDLE"); path != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading ROPE_CA_BUNDLE: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("reading ROPE_CA_BUNDLE: no certificates found in '%s'", path)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+	}
+	auth.Base = &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+		ForceAttemptHTTP2:     true,
+	}
+
+	return &http.Client{
+		Transport: &httpCache{
+			Base: &retryTransport{
+				Base:       auth,
+				Retries:    retries,
+				MinBackoff: 500 * time.Millisecond,
+				MaxBackoff: 10 * time.Second,
+			},
+			Cache:   cache,
+			Offline: offline,
+		},
+	}, nil
+}
+
+// userAgent identifies rope to package indexes.
+func userAgent() string {
+	return fmt.Sprintf("rope/%s (+https://github.com/AlexanderEkdahl/rope; %s/%s)", Version, runtime.GOOS, runtime.GOARCH)
+}
+
+// retryTransport retries requests failing due to server errors or
+// connection errors using exponential backoff. Only requests that can be
+// safely replayed are retried.
+type retryTransport struct {
+	Base    http.RoundTripper
+	Retries int
+	// MinBackoff is the delay before the first retry. The delay is doubled
+	// for every subsequent retry up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Header.Get("User-Agent") == "" {
+		r = r.Clone(r.Context())
+		r.Header.Set("User-Agent", userAgent())
+	}
+
+	replayable := (r.Method == http.MethodGet || r.Method == http.MethodHead) && (r.Body == nil || r.Body == http.NoBody)
+
+	backoff := t.MinBackoff
+	for attempt := 0; ; attempt++ {
+		res, err := t.Base.RoundTrip(r)
+		if !replayable || attempt >= t.Retries || !retryable(res, err) {
+			return res, err
+		}
+
+		delay, ok := time.Duration(0), false
+		if res != nil {
+			delay, ok = parseRetryAfter(res.Header.Get("Retry-After"))
+			// Drain the body to allow the connection to be reused.
+			io.Copy(ioutil.Discard, io.LimitReader(res.Body, 1<<16))
+			res.Body.Close()
+		}
+		if !ok && backoff > 0 {
+			// Add jitter to avoid retrying in lockstep with other clients.
+			delay = backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))
+		}
+		if delay > maxRetryAfter {
+			delay = maxRetryAfter
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return nil, r.Context().Err()
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > t.MaxBackoff {
+			backoff = t.MaxBackoff
+		}
+	}
+}
+
+// retryable returns true if the request resulting in res or err should be
+// retried.
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+
+		var netErr net.Error
+		return errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, syscall.ECONNREFUSED) ||
+			errors.Is(err, io.ErrUnexpectedEOF) ||
+			errors.Is(err, io.EOF) ||
+			errors.As(err, &netErr) && netErr.Timeout()
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter parses the value of the Retry-After header which is either
+// a number of seconds or a HTTP date.
+func parseRetryAfter(s string) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(s); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(s); err == nil {
+		delay := time.Until(t)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
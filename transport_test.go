@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if !strings.HasPrefix(r.Header.Get("User-Agent"), "rope/") {
+			t.Errorf("unexpected user agent: %s", r.Header.Get("User-Agent"))
+		}
+
+		switch r.URL.Path {
+		case "/flaky":
+			if attempts < 3 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &retryTransport{
+			Base:       http.DefaultTransport,
+			Retries:    3,
+			MinBackoff: time.Millisecond,
+			MaxBackoff: 2 * time.Millisecond,
+		},
+	}
+
+	testCases := []struct {
+		path     string
+		status   int
+		attempts int
+	}{
+		{"/flaky", http.StatusOK, 3},
+		{"/missing", http.StatusNotFound, 1},
+		{"/broken", http.StatusBadGateway, 4},
+	}
+	for _, tc := range testCases {
+		attempts = 0
+		res, err := client.Get(server.URL + tc.path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.path, err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != tc.status {
+			t.Fatalf("%s: wrong status, got: %d, want: %d", tc.path, res.StatusCode, tc.status)
+		}
+		if attempts != tc.attempts {
+			t.Fatalf("%s: wrong number of attempts, got: %d, want: %d", tc.path, attempts, tc.attempts)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d, ok := parseRetryAfter("120"); !ok || d != 2*time.Minute {
+		t.Fatalf("unexpected delay: %s", d)
+	}
+	if d, ok := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); !ok || d < 59*time.Minute {
+		t.Fatalf("unexpected delay: %s", d)
+	}
+	if _, ok := parseRetryAfter("soon"); ok {
+		t.Fatalf("expected invalid Retry-After to be rejected")
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+/*
+
+rope sync --venv creates a virtual environment(PEP 405) in the project
+directory as an alternative to configuring PYTHONPATH. The contents of the
+site-packages directory of every installed package is hardlinked into the
+site-packages directory of the virtual environment which allows the
+interpreter to process .pth files and to merge namespace packages split across
+distributions.
+
+<project> / .venv / pyvenv.cfg
+                  / bin / python -> <interpreter>
+                  / lib / python<X.Y> / site-packages
+
+*/
+
+const venvDir = ".venv"
+
+// venvStamp records the installations linked into the virtual environment to
+// avoid relinking when nothing has changed.
+const venvStamp = "rope-installed"
+
+// projectVenv returns the path to the virtual environment of the project.
+func projectVenv() (string, error) {
+	ropefilePath, err := FindRopefile()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Abs(filepath.Join(filepath.Dir(ropefilePath), venvDir))
+}
+
+// isRopeVenv returns true if dir contains a virtual environment created by
+// rope.
+func isRopeVenv(dir string) bool {
+	cfg, err := readPyvenvCfg(filepath.Join(dir, "pyvenv.cfg"))
+	if err != nil {
+		return false
+	}
+	_, ok := cfg["rope"]
+	return ok
+}
+
+// syncVenv creates or updates the virtual environment at dir to contain the
+// installations identified by the site-packages directories in paths.
+// Packages earlier in paths take precedence in the event of conflicting files.
+func syncVenv(dir string, paths []string) error {
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("virtual environments are not supported on windows")
+	}
+
+	if _, err := os.Stat(dir); err == nil {
+		if !isRopeVenv(dir) {
+			return fmt.Errorf("'%s' exists and was not created by rope", dir)
+		}
+
+		stamp, err := ioutil.ReadFile(filepath.Join(dir, venvStamp))
+		if err == nil && string(stamp) == strings.Join(paths, "\n") {
+			return nil
+		}
+
+		// The virtual environment is recreated to remove files of removed
+		// dependencies.
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	interpreter, err := env.Interpreter()
+	if err != nil {
+		return err
+	}
+	pythonVersion, err := env.Get("python_version")
+	if err != nil {
+		return err
+	}
+	pythonFullVersion, err := env.Get("python_full_version")
+	if err != nil {
+		return err
+	}
+
+	bin := filepath.Join(dir, "bin")
+	sitePackages := filepath.Join(dir, "lib", "python"+pythonVersion, "site-packages")
+	for _, d := range []string{bin, sitePackages} {
+		if err := os.MkdirAll(d, 0777); err != nil {
+			return err
+		}
+	}
+
+	cfg := &bytes.Buffer{}
+	fmt.Fprintf(cfg, "home = %s\n", filepath.Dir(interpreter))
+	fmt.Fprintf(cfg, "include-system-site-packages = false\n")
+	fmt.Fprintf(cfg, "version = %s\n", pythonFullVersion)
+	fmt.Fprintf(cfg, "rope = %s\n", Version)
+	if err := ioutil.WriteFile(filepath.Join(dir, "pyvenv.cfg"), cfg.Bytes(), 0666); err != nil {
+		return err
+	}
+
+	python := filepath.Join(bin, "python")
+	for _, name := range []string{"python", "python3", "python" + pythonVersion} {
+		if err := os.Symlink(interpreter, filepath.Join(bin, name)); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range paths {
+		if err := linkTree(path, sitePackages); err != nil {
+			return err
+		}
+		if err := copyScripts(scriptsPath(path), bin, interpreter, python); err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, venvStamp), []byte(strings.Join(paths, "\n")), 0666)
+}
+
+// linkTree hardlinks every file in src into dst. Files are symlinked if
+// hardlinking fails, e.g. when src and dst are on different devices. Existing
+// files in dst are not replaced.
+func linkTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, 0777)
+		case info.Mode()&os.ModeSymlink != 0:
+			linkname, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(linkname, target); err != nil && !errors.Is(err, os.ErrExist) {
+				return err
+			}
+			return nil
+		}
+
+		err = os.Link(path, target)
+		if errors.Is(err, os.ErrExist) {
+			return nil
+		} else if err != nil {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(abs, target); err != nil && !errors.Is(err, os.ErrExist) {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// copyScripts copies the scripts in src into dst rewriting shebangs
+// referring to interpreter to instead refer to python.
+func copyScripts(src, dst, interpreter, python string) error {
+	infos, err := ioutil.ReadDir(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	shebang := []byte("#!" + interpreter)
+	for _, info := range infos {
+		target := filepath.Join(dst, info.Name())
+		if _, err := os.Lstat(target); err == nil {
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(filepath.Join(src, info.Name()))
+		if err != nil {
+			return err
+		}
+		if bytes.HasPrefix(contents, shebang) {
+			contents = append([]byte("#!"+python), contents[len(shebang):]...)
+		}
+
+		if err := ioutil.WriteFile(target, contents, 0777); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readPyvenvCfg reads the key-value pairs of a pyvenv.cfg file.
+func readPyvenvCfg(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		sep := strings.Index(line, "=")
+		if sep < 0 {
+			continue
+		}
+		cfg[strings.TrimSpace(line[:sep])] = strings.TrimSpace(line[sep+1:])
+	}
+
+	return cfg, scanner.Err()
+}
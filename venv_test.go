@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncVenv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rope-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Provide a fake interpreter and a resolved environment.
+	interpreter := filepath.Join(dir, "python")
+	if err := ioutil.WriteFile(interpreter, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", oldPath)
+	os.Setenv("PATH", dir)
+
+	oldEnv := env
+	defer func() {
+		env = oldEnv
+	}()
+	env = &Environment{env: map[string]string{
+		"python_version":      "3.8",
+		"python_full_version": "3.8.5",
+	}}
+	env.init.Do(func() {})
+
+	// Two distributions sharing the namespace package 'ns'.
+	var paths []string
+	for _, name := range []string{"a", "b"} {
+		scheme := newInstallScheme(filepath.Join(dir, "ropedir", installVersion, name+"-1.0-py3-none-any"), name)
+		files := map[string]string{
+			filepath.Join(scheme.Purelib, "ns", name, "__init__.py"): "",
+			filepath.Join(scheme.Purelib, name+".pth"):               "import " + name,
+			filepath.Join(scheme.Scripts, name):                      "#!" + interpreter + "\nimport " + name,
+		}
+		for path, contents := range files {
+			if err := writeFile(path, []byte(contents), 0444); err != nil {
+				t.Fatal(err)
+			}
+		}
+		paths = append(paths, scheme.Purelib)
+	}
+
+	venv := filepath.Join(dir, venvDir)
+	if err := syncVenv(venv, paths); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !isRopeVenv(venv) {
+		t.Fatalf("expected venv to be created by rope")
+	}
+	cfg, err := readPyvenvCfg(filepath.Join(venv, "pyvenv.cfg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg["home"] != dir || cfg["version"] != "3.8.5" {
+		t.Fatalf("unexpected pyvenv.cfg: %v", cfg)
+	}
+	if linkname, err := os.Readlink(filepath.Join(venv, "bin", "python3.8")); err != nil || linkname != interpreter {
+		t.Fatalf("unexpected interpreter link, got: %s (%v), want: %s", linkname, err, interpreter)
+	}
+
+	sitePackages := filepath.Join(venv, "lib", "python3.8", "site-packages")
+	for _, name := range []string{"a", "b"} {
+		installed, err := os.Stat(filepath.Join(dir, "ropedir", installVersion, name+"-1.0-py3-none-any", "site-packages", name+".pth"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		linked, err := os.Stat(filepath.Join(sitePackages, name+".pth"))
+		if err != nil {
+			t.Fatalf("expected .pth file to be linked: %v", err)
+		}
+		if !os.SameFile(installed, linked) {
+			t.Fatalf("expected .pth file to be hardlinked")
+		}
+		if _, err := os.Stat(filepath.Join(sitePackages, "ns", name, "__init__.py")); err != nil {
+			t.Fatalf("expected namespace packages to be merged: %v", err)
+		}
+
+		script, err := ioutil.ReadFile(filepath.Join(venv, "bin", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := "#!" + filepath.Join(venv, "bin", "python") + "\n"; !strings.HasPrefix(string(script), expected) {
+			t.Fatalf("unexpected script, got: %s, want prefix: %s", script, expected)
+		}
+	}
+
+	// Syncing again without any changes is a no-op.
+	if err := syncVenv(venv, paths); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Removed dependencies are removed from the virtual environment.
+	if err := syncVenv(venv, paths[:1]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(sitePackages, "b.pth")); !os.IsNotExist(err) {
+		t.Fatalf("expected files of removed dependency to be removed")
+	}
+
+	// Virtual environments not created by rope are left untouched.
+	other := filepath.Join(dir, "other")
+	if err := writeFile(filepath.Join(other, "pyvenv.cfg"), []byte("home = /usr/bin\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncVenv(other, paths); err == nil {
+		t.Fatalf("expected error for virtual environment not created by rope")
+	}
+}
@@ -216,6 +216,12 @@ func versionRequirement(p *parser) (Requirement, error) {
 	if !valid {
 		return Requirement{}, fmt.Errorf("invalid version '%s'", versionString)
 	}
+	if version.Wildcard && op != Equal && op != NotEqual {
+		return Requirement{}, fmt.Errorf("invalid version '%s': wildcards are only allowed with '==' and '!='", versionString)
+	}
+	if op == CompatibleEqual && version.ReleaseVersions < 2 {
+		return Requirement{}, fmt.Errorf("invalid version '%s': '~=' requires at least two release segments", versionString)
+	}
 
 	return Requirement{
 		Operator: op,
@@ -236,6 +236,10 @@ func TestDependencyEvaluation(t *testing.T) {
 		},
 		{
 			input:   `numpy[windows]`,
+			install: true,
+		},
+		{
+			input:   `numpy[test]; extra == 'windows'`,
 			install: false,
 		},
 		{
@@ -254,6 +258,22 @@ func TestDependencyEvaluation(t *testing.T) {
 			input:   `test; python_version>'3.8'`,
 			install: false,
 		},
+		{
+			input:   `test; python_version~='3.5'`,
+			install: true,
+		},
+		{
+			input:   `test; python_version~='3.7'`,
+			install: false,
+		},
+		{
+			input:   `test; python_version=='3.*'`,
+			install: true,
+		},
+		{
+			input:   `test; python_version!='3.6.*'`,
+			install: false,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.input, func(t *testing.T) {
@@ -2,9 +2,20 @@ package version
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 )
 
+// extraRe matches the runs of separators replaced when normalizing extras.
+var extraRe = regexp.MustCompile(`[-_.]+`)
+
+// NormalizeExtra normalizes the name of an extra in the same way as package
+// names, e.g. 'Dev_Tools' becomes 'dev-tools'.
+// https://www.python.org/dev/peps/pep-0685/
+func NormalizeExtra(extra string) string {
+	return strings.ToLower(extraRe.ReplaceAllString(extra, "-"))
+}
+
 // Env represents a Python environment.
 type Env interface {
 	Get(k string) (string, error)
@@ -15,22 +26,12 @@ type Expr interface {
 	Evaluate(env Env) (bool, error)
 }
 
-// Evaluate returns true if the dependency should be installed in the given environment.
+// Evaluate returns true if the dependency should be installed in the given
+// environment. The extras of the dependency do not affect the result as they
+// select optional dependencies of the dependency itself; dependencies only
+// required by an extra of the dependant are instead marked using the 'extra'
+// environment marker.
 func (d *Dependency) Evaluate(env Env) (bool, error) {
-	matchingExtra := true
-	if len(d.Extras) > 0 {
-		matchingExtra = false
-		for _, e := range d.Extras {
-			if e2, _ := env.Get("extra"); e == e2 {
-				matchingExtra = true
-				break
-			}
-		}
-	}
-	if !matchingExtra {
-		return false, nil
-	}
-
 	// If multiple environment markers are provided all of them must evaluate to true.
 	// This logic should be verified as it is not explicitly mentioned in PEP 508.
 	for _, sub := range d.expr {
@@ -71,6 +72,12 @@ func (e exprMarker) Evaluate(env Env) (bool, error) {
 		}
 	}
 
+	// Extras are compared using their normalized names as wheels may refer to
+	// 'dev_tools' or 'Dev-Tools' while requesting 'dev-tools'.
+	if e.left.env && e.left.value == "extra" || e.right.env && e.right.value == "extra" {
+		left, right = NormalizeExtra(left), NormalizeExtra(right)
+	}
+
 	// Use PEP 440 version comparison operators if both sides are valid versions
 	// and the operator is defined in the set of comparison operators for versions.
 	if e.op == "in" {
@@ -89,21 +96,8 @@ func (e exprMarker) Evaluate(env Env) (bool, error) {
 
 	if leftValidVersion && rightValidVersion {
 		switch e.op {
-		case LessOrEqual:
-			return Compare(leftVersion, rightVersion) <= 0, nil
-		case Less:
-			return Compare(leftVersion, rightVersion) < 0, nil
-		case NotEqual:
-			return Compare(leftVersion, rightVersion) != 0, nil
-		case Equal:
-			return Compare(leftVersion, rightVersion) == 0, nil
-		case GreaterOrEqual:
-			return Compare(leftVersion, rightVersion) >= 0, nil
-		case Greater:
-			return Compare(leftVersion, rightVersion) > 0, nil
-		case CompatibleEqual:
-			// TODO: Implement support for ~=
-			return false, nil
+		case LessOrEqual, Less, NotEqual, Equal, GreaterOrEqual, Greater, CompatibleEqual:
+			return Requirement{Operator: e.op, Version: rightVersion}.Contains(leftVersion), nil
 		default:
 			return false, fmt.Errorf("unsupported version comparison operator: '%s'", e.op)
 		}
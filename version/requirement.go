@@ -2,7 +2,6 @@ package version
 
 import (
 	"fmt"
-	"os"
 )
 
 // Version comparison operators
@@ -30,23 +29,47 @@ func (vr Requirement) String() string {
 	return fmt.Sprintf("%s%s", vr.Operator, vr.Version)
 }
 
+// Contains returns true if the version v satisfies the requirement.
+// https://www.python.org/dev/peps/pep-0440/#version-specifiers
 func (vr Requirement) Contains(v Version) bool {
 	switch vr.Operator {
 	case LessOrEqual:
 		return Compare(v, vr.Version) <= 0
 	case Less:
+		// <V excludes pre-releases of V unless V is a pre-release, e.g.
+		// '<2.0' does not contain 2.0rc1.
+		if !vr.Version.Prerelease() && v.Prerelease() && v.sameRelease(vr.Version) {
+			return false
+		}
 		return Compare(v, vr.Version) < 0
 	case NotEqual:
+		if vr.Version.Wildcard {
+			return !v.hasPrefix(vr.Version, vr.Version.ReleaseVersions)
+		}
 		return Compare(v, vr.Version) != 0
 	case Equal:
+		if vr.Version.Wildcard {
+			return v.hasPrefix(vr.Version, vr.Version.ReleaseVersions)
+		}
 		return Compare(v, vr.Version) == 0
 	case GreaterOrEqual:
 		return Compare(v, vr.Version) >= 0
 	case Greater:
+		// >V excludes post-releases of V unless V is a post-release and
+		// local versions of V, e.g. '>1.7' does not contain 1.7.post1.
+		if !vr.Version.PostRelease && v.PostRelease && v.sameRelease(vr.Version) {
+			return false
+		}
+		if v.LocalVersion != "" && v.sameRelease(vr.Version) {
+			return false
+		}
 		return Compare(v, vr.Version) > 0
 	case CompatibleEqual:
-		fmt.Fprintf(os.Stderr, "❗️ '~=' not supported")
-		return false
+		// ~=V.N is equivalent to >=V.N, ==V.*
+		if vr.Version.ReleaseVersions < 2 || vr.Version.Wildcard {
+			return false
+		}
+		return Compare(v, vr.Version) >= 0 && v.hasPrefix(vr.Version, vr.Version.ReleaseVersions-1)
 	case TripleEqual:
 		// Treat === as equivalent to == (should be string equality)
 		return Compare(v, vr.Version) == 0
@@ -62,6 +85,8 @@ func (vr Requirement) Contains(v Version) bool {
 //
 // 	<1.19.0, >=1.16.0 -> 1.16.0
 // 	<1.3.4, >=1.3.6 -> 1.3.6
+// 	~=1.4.5 -> 1.4.5
+// 	==2.0.* -> 2.0
 //
 // The intention of this function is to extract the minimal version the
 // package was verified to work with.
@@ -74,8 +99,11 @@ func Minimal(vrs []Requirement) Version {
 	for _, vr := range vrs {
 		switch vr.Operator {
 		case GreaterOrEqual, CompatibleEqual, Equal, TripleEqual:
-			if vr.Version.GreaterThan(highestLowerBound) {
-				highestLowerBound = vr.Version
+			// The lowest version matching a prefix is the prefix itself.
+			lowerBound := vr.Version
+			lowerBound.Wildcard = false
+			if lowerBound.GreaterThan(highestLowerBound) {
+				highestLowerBound = lowerBound
 			}
 		}
 	}
@@ -38,6 +38,18 @@ func TestMinimal(t *testing.T) {
 			},
 			MustParse("1.15"),
 		},
+		{
+			[]Requirement{
+				{CompatibleEqual, MustParse("1.4.5")},
+			},
+			MustParse("1.4.5"),
+		},
+		{
+			[]Requirement{
+				{Equal, MustParse("2.0.*")},
+			},
+			MustParse("2.0"),
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(fmt.Sprintf("%s", tc.input), func(t *testing.T) {
@@ -49,8 +61,83 @@ func TestMinimal(t *testing.T) {
 }
 
 func TestRequirementContains(t *testing.T) {
-	vr, _ := ParseVersionRequirements(">= 3.6")
-	if vr[0].Contains(MustParse("3.5")) {
-		t.Fatalf("did not expect >=3.6 to contain 3.5")
+	// Examples from https://www.python.org/dev/peps/pep-0440/#version-specifiers
+	testCases := []struct {
+		requirement string
+		version     string
+		contains    bool
+	}{
+		{">= 3.6", "3.5", false},
+		{">= 3.6", "3.6", true},
+
+		// Compatible release
+		{"~=2.2", "2.2", true},
+		{"~=2.2", "2.3", true},
+		{"~=2.2", "2.9.1", true},
+		{"~=2.2", "2.2.post3", true},
+		{"~=2.2", "2.1", false},
+		{"~=2.2", "3.0", false},
+		{"~=1.4.5", "1.4.5", true},
+		{"~=1.4.5", "1.4.9", true},
+		{"~=1.4.5", "1.4.4", false},
+		{"~=1.4.5", "1.5.0", false},
+		{"~=2.2.post3", "2.2.post3", true},
+		{"~=2.2.post3", "2.2.post4", true},
+		{"~=2.2.post3", "2.3", true},
+		{"~=2.2.post3", "2.2", false},
+		{"~=2.2.post3", "3.0", false},
+		{"~=1.4.5a4", "1.4.5a4", true},
+		{"~=1.4.5a4", "1.4.5", true},
+		{"~=1.4.5a4", "1.4.6", true},
+		{"~=1.4.5a4", "1.4.5a3", false},
+		{"~=1.4.5a4", "1.5", false},
+		{"~=2.2.0", "2.2.1", true},
+		{"~=2.2.0", "2.3.0", false},
+		{"~=1.4.5.0", "1.4.5.1", true},
+		{"~=1.4.5.0", "1.4.6", false},
+		{"~=1!2.2", "1!2.5", true},
+		{"~=1!2.2", "2.5", false},
+
+		// Version matching
+		{"==1.1", "1.1", true},
+		{"==1.1", "1.1.0", true},
+		{"==1.1", "1.1.post1", false},
+		{"==1.1", "1.1a1", false},
+		{"==1.1.post1", "1.1.post1", true},
+		{"==1.1.*", "1.1", true},
+		{"==1.1.*", "1.1.0", true},
+		{"==1.1.*", "1.1.5", true},
+		{"==1.1.*", "1.1.post1", true},
+		{"==1.1.*", "1.1a1", true},
+		{"==1.1.*", "1.2", false},
+		{"==1.1.*", "1.10", false},
+		{"==1.*", "1.9.9", true},
+		{"==1.0.*", "1", true},
+		{"==1.*", "2.0", false},
+
+		// Version exclusion
+		{"!=1.1", "1.1.0", false},
+		{"!=1.1", "1.1.post1", true},
+		{"!=1.1.*", "1.1.5", false},
+		{"!=1.1.*", "1.2", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.requirement+" "+tc.version, func(t *testing.T) {
+			vrs, err := ParseVersionRequirements(tc.requirement)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contains := vrs[0].Contains(MustParse(tc.version)); contains != tc.contains {
+				t.Fatalf("got: %v, want: %v", contains, tc.contains)
+			}
+		})
+	}
+}
+
+func TestInvalidRequirements(t *testing.T) {
+	for _, input := range []string{"~=1", "~=2.*", ">=1.*", "<2.0.*"} {
+		if _, err := ParseVersionRequirements(input); err == nil {
+			t.Fatalf("%s: expected error", input)
+		}
 	}
 }
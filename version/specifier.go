@@ -0,0 +1,149 @@
+package version
+
+import (
+	"sort"
+	"strings"
+)
+
+// SpecifierSet is a list of requirements that must all be satisfied, e.g.
+// '>=1.16, <1.19, !=1.17.1'. The empty set contains every version.
+// https://www.python.org/dev/peps/pep-0440/#version-specifiers
+type SpecifierSet []Requirement
+
+// ParseSpecifierSet parses comma separated version requirements. An empty
+// input results in the empty set.
+func ParseSpecifierSet(input string) (SpecifierSet, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, nil
+	}
+
+	vrs, err := ParseVersionRequirements(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return SpecifierSet(vrs), nil
+}
+
+func (s SpecifierSet) String() string {
+	parts := make([]string, 0, len(s))
+	for _, vr := range s {
+		parts = append(parts, vr.Operator+vr.Version.Canonical())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Contains returns true if the version v satisfies every requirement.
+func (s SpecifierSet) Contains(v Version) bool {
+	for _, vr := range s {
+		if !vr.Contains(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the candidates satisfying every requirement sorted in
+// ascending order.
+func (s SpecifierSet) Filter(candidates []Version) []Version {
+	var filtered []Version
+	for _, v := range candidates {
+		if s.Contains(v) {
+			filtered = append(filtered, v)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return Compare(filtered[i], filtered[j]) < 0
+	})
+	return filtered
+}
+
+// Prereleases returns true if any requirement explicitly references a
+// pre-release, e.g. '>=2.0rc1'.
+func (s SpecifierSet) Prereleases() bool {
+	for _, vr := range s {
+		if vr.Version.Prerelease() {
+			return true
+		}
+	}
+	return false
+}
+
+// Intersect returns the set of versions contained in both s and s2.
+func (s SpecifierSet) Intersect(s2 SpecifierSet) SpecifierSet {
+	intersection := make(SpecifierSet, 0, len(s)+len(s2))
+	seen := make(map[Requirement]bool, len(s)+len(s2))
+	for _, vr := range append(append(SpecifierSet{}, s...), s2...) {
+		if seen[vr] {
+			continue
+		}
+		seen[vr] = true
+		intersection = append(intersection, vr)
+	}
+	return intersection
+}
+
+// bound is the lower or upper bound of a set.
+type bound struct {
+	version   Version
+	inclusive bool
+}
+
+// Empty returns true if no version can satisfy every requirement. Only sets
+// that are provably empty are reported, e.g. '>=2, <1' or '==1.0, !=1.0'.
+func (s SpecifierSet) Empty() bool {
+	var lower, upper *bound
+	raise := func(b bound) {
+		if lower == nil || Compare(b.version, lower.version) > 0 || Compare(b.version, lower.version) == 0 && !b.inclusive {
+			lower = &b
+		}
+	}
+	reduce := func(b bound) {
+		if upper == nil || Compare(b.version, upper.version) < 0 || Compare(b.version, upper.version) == 0 && !b.inclusive {
+			upper = &b
+		}
+	}
+
+	for _, vr := range s {
+		switch vr.Operator {
+		case GreaterOrEqual:
+			raise(bound{vr.Version, true})
+		case Greater:
+			raise(bound{vr.Version, false})
+		case LessOrEqual:
+			reduce(bound{vr.Version, true})
+		case Less:
+			reduce(bound{vr.Version, false})
+		case Equal, TripleEqual:
+			// A wildcard compares equal to every version matching the prefix.
+			raise(bound{vr.Version, true})
+			reduce(bound{vr.Version, true})
+		case CompatibleEqual:
+			if vr.Version.ReleaseVersions < 2 || vr.Version.Wildcard {
+				return true
+			}
+			raise(bound{vr.Version, true})
+			reduce(bound{vr.Version.prefix(vr.Version.ReleaseVersions - 1), true})
+		}
+	}
+
+	if lower == nil || upper == nil {
+		return false
+	}
+
+	switch c := Compare(lower.version, upper.version); {
+	case c > 0:
+		return true
+	case c < 0:
+		return false
+	case lower.version.Wildcard || upper.version.Wildcard:
+		// Pre-releases may still match a prefix while comparing equal to it.
+		return false
+	case !lower.inclusive || !upper.inclusive:
+		return true
+	default:
+		// Only a single version remains which may be excluded.
+		return !s.Contains(lower.version)
+	}
+}
@@ -0,0 +1,117 @@
+package version
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpecifierSetContains(t *testing.T) {
+	testCases := []struct {
+		input    string
+		version  string
+		contains bool
+	}{
+		{"", "1.0", true},
+		{">=1.16, <1.19", "1.18.5", true},
+		{">=1.16, <1.19", "1.19", false},
+		{">=1.16, <1.19, !=1.17.1", "1.17.1", false},
+		{"~=1.4.5, !=1.4.7", "1.4.6", true},
+		{"~=1.4.5, !=1.4.7", "1.4.7", false},
+		{"!=2.0.*, >=1.15", "2.0.1", false},
+		{"!=2.0.*, >=1.15", "2.1", true},
+		// Exclusive ordered comparisons(PEP 440).
+		{">1.7", "1.7.1", true},
+		{">1.7", "1.7.post1", false},
+		{">1.7", "1.7.0.post1", false},
+		{">1.7", "1.7+local", false},
+		{">1.7.post2", "1.7.post3", true},
+		{">1.7.post2", "1.7.1", true},
+		{"<3.1", "3.0.5", true},
+		{"<3.1", "3.1.dev0", false},
+		{"<3.1", "3.1rc1", false},
+		{"<2.0", "2.0.0rc1", false},
+		{"<2.0", "2.0.post1", false},
+		{"<3.1rc2", "3.1rc1", true},
+		{">=1.26rc1, <2", "2.0.0rc1", false},
+		{">=1.26rc1, <2", "1.26rc1", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input+" "+tc.version, func(t *testing.T) {
+			s, err := ParseSpecifierSet(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contains := s.Contains(MustParse(tc.version)); contains != tc.contains {
+				t.Fatalf("got: %v, want: %v", contains, tc.contains)
+			}
+		})
+	}
+}
+
+func TestSpecifierSetFilter(t *testing.T) {
+	s, err := ParseSpecifierSet(">=1.0, <2, !=1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var candidates []Version
+	for _, v := range []string{"2.0", "1.2", "0.9", "1.1", "1.0.1", "1.0"} {
+		candidates = append(candidates, MustParse(v))
+	}
+
+	var got []string
+	for _, v := range s.Filter(candidates) {
+		got = append(got, v.String())
+	}
+	if want := []string{"1.0", "1.0.1", "1.2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestSpecifierSetIntersect(t *testing.T) {
+	a, _ := ParseSpecifierSet(">=1.0, <2")
+	b, _ := ParseSpecifierSet("<2, !=1.5")
+
+	intersection := a.Intersect(b)
+	if got, want := intersection.String(), ">=1.0, <2, !=1.5"; got != want {
+		t.Fatalf("got: %s, want: %s", got, want)
+	}
+	if intersection.Contains(MustParse("1.5")) || !intersection.Contains(MustParse("1.6")) {
+		t.Fatalf("unexpected intersection: %s", intersection)
+	}
+}
+
+func TestSpecifierSetEmpty(t *testing.T) {
+	testCases := []struct {
+		input string
+		empty bool
+	}{
+		{"", false},
+		{">=1.0", false},
+		{">=1.0, <2", false},
+		{">=2, <1", true},
+		{">=1.0, <1.0", true},
+		{">1.0, <=1.0", true},
+		{">=1.0, <=1.0", false},
+		{"==1.0, !=1.0", true},
+		{"==1.0, >=1.0", false},
+		{"==1.0, ==1.1", true},
+		{"~=2.2, >=3", true},
+		{"~=2.2, <2.2", true},
+		{"~=2.2, <2.3", false},
+		{"==1.1.*, >=1.1.5", false},
+		{"==1.1.*, >=1.2", true},
+		{"==1.1.*, <1.1", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			s, err := ParseSpecifierSet(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if empty := s.Empty(); empty != tc.empty {
+				t.Fatalf("got: %v, want: %v", empty, tc.empty)
+			}
+		})
+	}
+}
@@ -224,17 +224,19 @@ func (v Version) Match(v2 Version) bool {
 		return false
 	}
 
+	// If either version is a wildcard only the prefix of the release is matched.
+	if v.Wildcard {
+		return v2.hasPrefix(v, v.ReleaseVersions)
+	} else if v2.Wildcard {
+		return v.hasPrefix(v2, v2.ReleaseVersions)
+	}
+
 	// equivalent to "zero-padding" the release
 	for i := range v.Release {
 		if v.Release[i] != v2.Release[i] {
 			return false
 		}
 	}
-	if v.Wildcard || v2.Wildcard {
-		// If the epoch and release versions are a match and either version is a wildcard
-		// then the versions match.
-		return true
-	}
 
 	if v.PreReleasePhase != v2.PreReleasePhase {
 		return false
@@ -262,6 +264,48 @@ func (v Version) Match(v2 Version) bool {
 	return true
 }
 
+// hasPrefix returns true if the epoch of v equals the epoch of prefix and the
+// release segment of v starts with the first n components of the release
+// segment of prefix. The release segments are zero-padded.
+// https://www.python.org/dev/peps/pep-0440/#version-matching
+func (v Version) hasPrefix(prefix Version, n int) bool {
+	if v.Epoch != prefix.Epoch {
+		return false
+	}
+
+	for i := 0; i < n && i < len(v.Release); i++ {
+		if v.Release[i] != prefix.Release[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sameRelease returns true if the epoch and the release segment of v equals
+// those of v2, i.e. the versions only differ in their pre-, post-, dev- or
+// local segment.
+func (v Version) sameRelease(v2 Version) bool {
+	return v.Epoch == v2.Epoch && v.Release == v2.Release
+}
+
+// prefix returns the wildcard version matching the first n components of the
+// release segment of v, e.g. 1.4.* for 1.4.5 and n = 2.
+func (v Version) prefix(n int) Version {
+	p := Version{
+		Epoch:           v.Epoch,
+		ReleaseVersions: n,
+		Wildcard:        true,
+	}
+	copy(p.Release[:n], v.Release[:n])
+	return p
+}
+
+// Prerelease returns true if v is a pre-release or a development release.
+func (v Version) Prerelease() bool {
+	return v.PreReleasePhase != 0 || v.DevRelease
+}
+
 // GreaterThan returns true if v is greater than v2.
 func (v Version) GreaterThan(v2 Version) bool {
 	return Compare(v, v2) == 1
@@ -282,7 +326,15 @@ func Compare(a, b Version) int {
 			return -1
 		}
 
-		for i := 0; i < len(a.Release); i++ {
+		// Only the prefix of the release is compared for wildcards.
+		n := len(a.Release)
+		if a.Wildcard && a.ReleaseVersions < n {
+			n = a.ReleaseVersions
+		}
+		if b.Wildcard && b.ReleaseVersions < n {
+			n = b.ReleaseVersions
+		}
+		for i := 0; i < n; i++ {
 			if a.Release[i] > b.Release[i] {
 				return 1
 			} else if a.Release[i] < b.Release[i] {
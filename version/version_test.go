@@ -231,6 +231,11 @@ func TestVersionMatch(t *testing.T) {
 			v2:    "1.1.*",
 			match: false,
 		},
+		{
+			v1:    "1.1.5",
+			v2:    "1.1.*",
+			match: true,
+		},
 		{
 			v1:    "1.1",
 			v2:    "1.1.0",
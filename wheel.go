@@ -97,6 +97,22 @@ type Wheel struct {
 	Path string
 	// URL is only set when the package was found in a remote package repository.
 	URL string
+	// Index is the URL of the package repository the wheel was found in. Empty
+	// if the wheel was built from a source distribution without an index.
+	Index string
+	// Source is the source distribution the wheel was built from. Nil if the
+	// wheel was downloaded from a package repository.
+	Source *LockedArtifact
+	// Hashes maps hash algorithms to the hex encoded digests of the wheel as
+	// provided by the package repository.
+	Hashes map[string]string
+	// Yanked is true if the wheel has been yanked from the package repository.
+	Yanked bool
+
+	// HasMetadata is true if the package repository serves the core metadata
+	// of the wheel separately(PEP 658).
+	HasMetadata    bool
+	MetadataHashes map[string]string
 
 	RequiresDist   []string
 	RequiresPython string
@@ -112,7 +128,7 @@ func (p *Wheel) Version() version.Version {
 }
 
 // TODO: Should take the environment as input
-func (p *Wheel) Dependencies() []Dependency {
+func (p *Wheel) Dependencies(extras []string) []Dependency {
 	var dependencies []Dependency
 
 	for _, row := range p.RequiresDist {
@@ -121,7 +137,12 @@ func (p *Wheel) Dependencies() []Dependency {
 			fmt.Fprintf(os.Stderr, "❗️ %s: %s(%v)\n", p.name, row, err)
 			continue
 		}
+		// Dependencies of an extra are marked using the extra environment
+		// marker which is empty unless evaluated for one of the extras.
 		install, err := dep.Evaluate(env)
+		for i := 0; err == nil && !install && i < len(extras); i++ {
+			install, err = dep.Evaluate(&EnvironmentExtra{extra: extras[i], env: env})
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "❗️ %s: %s(%v)\n", p.name, row, err)
 			continue
@@ -132,15 +153,19 @@ func (p *Wheel) Dependencies() []Dependency {
 
 		// fmt.Fprintf(os.Stderr, "🍀 %s: %s(minimal = %s)\n", name, row, version.Minimal(dep.Versions))
 		dependencies = append(dependencies, Dependency{
-			Name:    NormalizePackageName(dep.Name),
-			Version: version.Minimal(dep.Versions),
+			Name:        NormalizePackageName(dep.Name),
+			Version:     version.Minimal(dep.Versions),
+			Extras:      normalizeExtras(dep.Extras),
+			Requirement: row,
+			Specifiers:  version.SpecifierSet(dep.Versions),
 		})
 	}
 
 	return dependencies
 }
 
-// Install unpacks the wheel and returns the path to the installaction location.
+// Install installs the wheel into the shared store and links it into the
+// project. The returned path should be added to PYTHONPATH.
 func (p *Wheel) Install(ctx context.Context) (string, error) {
 	if err := p.fetch(ctx); err != nil {
 		return "", err
@@ -149,60 +174,55 @@ func (p *Wheel) Install(ctx context.Context) (string, error) {
 	filename := filepath.Base(p.Path)
 
 	// TODO: UserConfigDir?
-	installPath := filepath.Join("./ropedir", "0", strings.TrimSuffix(filename, ".whl"))
-	if _, err := os.Stat(installPath); errors.Is(err, os.ErrNotExist) {
-		// continue with installation
-	} else if err != nil {
+	prefix := filepath.Join("./ropedir", installVersion, strings.TrimSuffix(filename, ".whl"))
+	scheme := newInstallScheme(prefix, p.name)
+
+	sum, err := p.sum()
+	if err != nil {
 		return "", err
-	} else {
-		return installPath, nil
 	}
-	fmt.Println("installing wheel:", filename)
-
-	// TODO: Verify files as they are being read
-	whlFile, err := zip.OpenReader(p.Path)
+	interpreter, err := env.Interpreter()
 	if err != nil {
 		return "", err
 	}
-	defer whlFile.Close()
-
-	for _, file := range whlFile.File {
-		// TODO: Use Record to read files and verify SHA256
-		f, err := file.Open()
-		if err != nil {
-			return "", err
-		}
 
-		if file.FileInfo().IsDir() {
-			// Skip directories as parent directories are automatically
-			// created. However, this may cause issues if a package
-			// expects an empty folder in a certain location.
-			continue
-		}
+	// The view is only reused if it was built from the same wheel as it is
+	// named after the filename only.
+	key := storeKey(sum, interpreter)
+	if existing, err := viewKey(prefix); err != nil {
+		return "", err
+	} else if existing == key {
+		return scheme.Purelib, nil
+	}
 
-		target := filepath.Join(installPath, file.Name)
-		// TODO: Final directory should be created with 0500
-		if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
-			return "", err
-		}
+	dir, err := cache.Install(key, func(dir string) error {
+		fmt.Println("installing wheel:", filename)
+		return p.extract(newInstallScheme(dir, p.name))
+	})
+	if err != nil {
+		return "", err
+	}
 
-		// Write-protected files prevents users from inadvertendly modifying its
-		// dependencies and thereby affecing other projects.
-		dst, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0444)
-		if err != nil {
-			return "", err
-		}
+	if err := linkView(dir, prefix); err != nil {
+		return "", fmt.Errorf("linking installation into project: %w", err)
+	}
 
-		if _, err := io.Copy(dst, f); err != nil {
-			return "", err
-		}
+	return scheme.Purelib, nil
+}
 
-		if err := f.Close(); err != nil {
-			return "", err
+// sum returns the sha256 digest of the fetched wheel. The digest provided by
+// the index, or recorded in the lock(see checkLock), was verified when the
+// wheel was downloaded or retrieved from the cache and is used when present.
+func (p *Wheel) sum() ([]byte, error) {
+	if s := p.Hashes["sha256"]; s != "" {
+		sum, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sha256 digest '%s': %w", s, err)
 		}
+		return sum, nil
 	}
 
-	return installPath, nil
+	return fileSum(p.Path)
 }
 
 // fetch downloads the package from the remote index.
@@ -216,13 +236,17 @@ func (p *Wheel) fetch(ctx context.Context) error {
 		panic("wheel download: missing url")
 	}
 	fmt.Printf("Downloading %s\n", p.filename)
-	parsedURL, err := url.Parse(p.URL)
-	if err != nil {
-		return err
-	}
-	values, err := url.ParseQuery(parsedURL.Fragment)
-	if err != nil {
-		return err
+	expectedSum := p.Hashes["sha256"]
+	if expectedSum == "" {
+		parsedURL, err := url.Parse(p.URL)
+		if err != nil {
+			return err
+		}
+		values, err := url.ParseQuery(parsedURL.Fragment)
+		if err != nil {
+			return err
+		}
+		expectedSum = values.Get("sha256")
 	}
 
 	r, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
@@ -230,7 +254,7 @@ func (p *Wheel) fetch(ctx context.Context) error {
 		return err
 	}
 
-	res, err := http.DefaultClient.Do(r)
+	res, err := client.Do(r)
 	if err != nil {
 		return err
 	}
@@ -249,9 +273,9 @@ func (p *Wheel) fetch(ctx context.Context) error {
 	var sum []byte
 	var hash hash.Hash
 	var reader io.Reader = res.Body
-	if len(values["sha256"]) > 0 {
+	if expectedSum != "" {
 		var err error
-		sum, err = hex.DecodeString(values["sha256"][0])
+		sum, err = hex.DecodeString(expectedSum)
 		if err != nil {
 			return fmt.Errorf("sha256 checksum invalid hex: %w", err)
 		}
@@ -272,6 +296,12 @@ func (p *Wheel) fetch(ctx context.Context) error {
 		return fmt.Errorf("closing file after download: %w", err)
 	}
 
+	// Read the metadata before adding the wheel to the cache as it is stored
+	// in the cache index.
+	if err := p.readMetadataFromFile(file.Name()); err != nil {
+		return err
+	}
+
 	cachedPath, err := cache.AddWheel(p, file.Name())
 	if err != nil {
 		return err
@@ -281,46 +311,157 @@ func (p *Wheel) fetch(ctx context.Context) error {
 	return nil
 }
 
+// errMetadataNotFound is returned when the package repository advertises the
+// core metadata of a wheel but fails to serve it.
+var errMetadataNotFound = errors.New("metadata not found")
+
+// extractDependencies reads the dependencies from the core metadata of the
+// wheel. If possible only the metadata is downloaded and the wheel itself is
+// fetched during installation.
 func (p *Wheel) extractDependencies(ctx context.Context) error {
-	if err := p.fetch(ctx); err != nil {
+	if p.Path != "" {
+		return p.readMetadataFromFile(p.Path)
+	}
+
+	if p.HasMetadata {
+		err := p.fetchMetadata(ctx)
+		if !errors.Is(err, errMetadataNotFound) {
+			return err
+		}
+	}
+
+	err := p.fetchRemoteMetadata(ctx)
+	if err == nil || ctx.Err() != nil {
 		return err
 	}
 
-	whlFile, err := zip.OpenReader(p.Path)
+	// Fall back to downloading the whole wheel on any failure, e.g. servers
+	// refusing HEAD requests or truncating ranges. The metadata is read as
+	// part of adding the wheel to the cache.
+	return p.fetch(ctx)
+}
+
+// fetchRemoteMetadata reads the core metadata from the remote wheel using
+// HTTP range requests. Only the central directory of the archive and the
+// METADATA file are downloaded.
+func (p *Wheel) fetchRemoteMetadata(ctx context.Context) error {
+	r, err := newHTTPReaderAt(ctx, p.URL)
+	if err != nil {
+		return err
+	}
+
+	zr, err := zip.NewReader(r, r.Size())
+	if err != nil {
+		return err
+	}
+
+	return p.readMetadataFromZip(zr)
+}
+
+func (p *Wheel) readMetadataFromFile(path string) error {
+	whlFile, err := zip.OpenReader(path)
 	if err != nil {
 		return err
 	}
 	defer whlFile.Close()
 
-	var metadata *zip.File
-	for _, file := range whlFile.File {
-		// TODO: Verify contents of METADATA by also reading RECORD
-		if filepath.Base(file.Name) == "METADATA" {
-			metadata = file
-			break
-		}
+	return p.readMetadataFromZip(&whlFile.Reader)
+}
+
+// readMetadataFromZip reads the core metadata of the wheel after verifying
+// it against RECORD.
+func (p *Wheel) readMetadataFromZip(r *zip.Reader) error {
+	record, err := readRecord(r)
+	if err != nil {
+		return err
 	}
+
+	metadata := findDistInfo(r.File, "METADATA")
 	if metadata == nil {
 		return fmt.Errorf("METADATA file not found in .whl")
 	}
-	metadataFile, err := metadata.Open()
+
+	b := &bytes.Buffer{}
+	if err := verifyFile(metadata, record, b); err != nil {
+		return err
+	}
+
+	return p.readMetadata(b)
+}
+
+// fetchMetadata downloads the core metadata of the wheel served next to the
+// wheel by the package repository.
+// https://www.python.org/dev/peps/pep-0658/
+func (p *Wheel) fetchMetadata(ctx context.Context) error {
+	fmt.Fprintf(os.Stderr, "Downloading %s.metadata\n", p.filename)
+
+	r, err := newMetadataRequest(ctx, p.URL+".metadata")
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		// continue
+	case http.StatusNotFound:
+		return errMetadataNotFound
+	default:
+		return fmt.Errorf("failed HTTP request: %s", res.Status)
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		return err
 	}
-	defer metadataFile.Close()
 
-	scanner := bufio.NewScanner(metadataFile)
+	if expectedSum := p.MetadataHashes["sha256"]; expectedSum != "" {
+		sum, err := hex.DecodeString(expectedSum)
+		if err != nil {
+			return fmt.Errorf("metadata sha256 checksum invalid hex: %w", err)
+		}
+		if actual := sha256.Sum256(b); !bytes.Equal(sum, actual[:]) {
+			return fmt.Errorf("metadata checksum mismatch, got: %x, expected: %x", actual, sum)
+		}
+	}
+
+	return p.readMetadata(bytes.NewReader(b))
+}
+
+// readMetadata reads the dependencies and required Python version from the
+// core metadata of the wheel.
+// https://packaging.python.org/specifications/core-metadata/
+func (p *Wheel) readMetadata(r io.Reader) error {
+	var requiresDist []string
+	requiresPython := ""
+
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		row := scanner.Text()
-		// TODO: Extract requires-python
+		if row == "" {
+			// The headers are followed by an empty line and the description.
+			break
+		}
+
 		if strings.HasPrefix(row, "Requires-Dist:") {
-			row = strings.TrimSpace(strings.TrimPrefix(row, "Requires-Dist:"))
-			p.RequiresDist = append(p.RequiresDist, row)
+			requiresDist = append(requiresDist, strings.TrimSpace(strings.TrimPrefix(row, "Requires-Dist:")))
+		} else if strings.HasPrefix(row, "Requires-Python:") {
+			requiresPython = strings.TrimSpace(strings.TrimPrefix(row, "Requires-Python:"))
 		}
 	}
 	if err := scanner.Err(); err != nil {
 		return err
 	}
 
+	p.RequiresDist = requiresDist
+	if requiresPython != "" {
+		p.RequiresPython = requiresPython
+	}
+
 	return nil
 }
@@ -1,7 +1,21 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestParseWheelFilename(t *testing.T) {
@@ -17,3 +31,221 @@ func TestParseWheelFilename(t *testing.T) {
 	x, _ = ParseWheelFilename("x-1.0-0a-py2.py3-none-any.whl")
 	t.Logf("%#v\n", x)
 }
+
+func TestWheelMetadata(t *testing.T) {
+	metadata := "Metadata-Version: 2.1\nName: example\nVersion: 1.0\nRequires-Python: >=3.6\nRequires-Dist: six (>=1.0)\n\nRequires-Dist: not-a-header\n"
+	metadataSum := sha256.Sum256([]byte(metadata))
+
+	// The large file places METADATA outside of the first chunk requested
+	// from the end of the wheel.
+	data := make([]byte, 1<<20)
+	rand.Read(data)
+	wheel := buildTestWheel(t, []testFile{
+		{"example-1.0.dist-info/METADATA", []byte(metadata)},
+		{"example/data.bin", data},
+	})
+
+	fullDownloads := 0
+	serveMetadata := true
+	serveRanges := true
+	headStatus := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/example-1.0-py3-none-any.whl.metadata":
+			if !serveMetadata {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(metadata))
+		case "/example-1.0-py3-none-any.whl":
+			if r.Method == http.MethodGet && r.Header.Get("Range") == "" {
+				fullDownloads++
+			}
+			if r.Method == http.MethodHead && headStatus != 0 {
+				w.WriteHeader(headStatus)
+				return
+			}
+			if serveRanges {
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(wheel))
+				return
+			}
+			w.Write(wheel)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	oldCache := cache
+	defer func() {
+		cache = oldCache
+	}()
+	cache = &Cache{Temporary: true}
+	defer cache.Close()
+
+	testCases := []struct {
+		hasMetadata    bool
+		serveMetadata  bool
+		serveRanges    bool
+		headStatus     int
+		metadataHashes map[string]string
+		err            bool
+		fullDownloads  int
+	}{
+		{hasMetadata: true, serveMetadata: true, metadataHashes: map[string]string{"sha256": hex.EncodeToString(metadataSum[:])}},
+		{hasMetadata: true, serveMetadata: true, metadataHashes: map[string]string{"sha256": "00"}, err: true},
+		{hasMetadata: false, serveRanges: true},
+		{hasMetadata: false, serveRanges: false, fullDownloads: 1},
+		// Missing metadata falls back to range requests.
+		{hasMetadata: true, serveMetadata: false, serveRanges: true},
+		// Refused HEAD requests fall back to downloading the wheel.
+		{hasMetadata: false, serveRanges: true, headStatus: http.StatusMethodNotAllowed, fullDownloads: 1},
+	}
+
+	for i, tc := range testCases {
+		fullDownloads = 0
+		serveMetadata = tc.serveMetadata
+		serveRanges = tc.serveRanges
+		headStatus = tc.headStatus
+		whl, err := ParseWheelFilename("example-1.0-py3-none-any.whl")
+		if err != nil {
+			t.Fatal(err)
+		}
+		whl.URL = server.URL + "/example-1.0-py3-none-any.whl"
+		whl.HasMetadata = tc.hasMetadata
+		whl.MetadataHashes = tc.metadataHashes
+
+		err = whl.extractDependencies(context.Background())
+		if tc.err {
+			if err == nil {
+				t.Fatalf("%d: expected error", i)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+
+		if expected := []string{"six (>=1.0)"}; !reflect.DeepEqual(whl.RequiresDist, expected) {
+			t.Fatalf("%d: unexpected Requires-Dist, got: %v, want: %v", i, whl.RequiresDist, expected)
+		}
+		if whl.RequiresPython != ">=3.6" {
+			t.Fatalf("%d: unexpected Requires-Python, got: %v, want: >=3.6", i, whl.RequiresPython)
+		}
+		if fullDownloads != tc.fullDownloads {
+			t.Fatalf("%d: unexpected number of full downloads, got: %d, want: %d", i, fullDownloads, tc.fullDownloads)
+		}
+		if (whl.Path != "") != (tc.fullDownloads > 0) {
+			t.Fatalf("%d: unexpected path: '%s'", i, whl.Path)
+		}
+	}
+}
+
+type testFile struct {
+	name string
+	data []byte
+}
+
+// buildTestWheel builds a wheel containing the files along with a RECORD
+// file listing them.
+func buildTestWheel(t *testing.T, files []testFile) []byte {
+	record := &bytes.Buffer{}
+	for _, f := range files {
+		sum := sha256.Sum256(f.data)
+		fmt.Fprintf(record, "%s,sha256=%s,%d\n", f.name, base64.RawURLEncoding.EncodeToString(sum[:]), len(f.data))
+	}
+	record.WriteString("example-1.0.dist-info/RECORD,,\n")
+	files = append(files, testFile{"example-1.0.dist-info/RECORD", record.Bytes()})
+
+	wheel := &bytes.Buffer{}
+	zw := zip.NewWriter(wheel)
+	for _, f := range files {
+		// Files are stored uncompressed to make the size of the wheel predictable.
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(f.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return wheel.Bytes()
+}
+
+func TestWheelDependenciesExtras(t *testing.T) {
+	oldEnv := env
+	defer func() {
+		env = oldEnv
+	}()
+	env = &Environment{env: map[string]string{"python_version": "3.8"}}
+	env.init.Do(func() {})
+
+	whl, err := ParseWheelFilename("urllib3-1.25.10-py2.py3-none-any.whl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	whl.RequiresDist = []string{
+		`pyOpenSSL>=0.14; extra == "secure"`,
+		`PySocks!=1.5.7,<2.0,>=1.5.6; extra == "socks"`,
+		`brotlipy>=0.6.0; extra == "brotli" and python_version < "3"`,
+		`idna[all]>=2.0.0; extra == "secure"`,
+		// Written by setuptools without normalizing the extra.
+		`pytest>=6; extra == "dev_tools"`,
+		`mypy; "Dev.Tools" == extra`,
+	}
+
+	testCases := []struct {
+		extras []string
+		want   []string
+	}{
+		{nil, nil},
+		{[]string{"secure"}, []string{"pyopenssl", "idna[all]"}},
+		{[]string{"secure", "socks"}, []string{"pyopenssl", "pysocks", "idna[all]"}},
+		{[]string{"brotli"}, nil},
+		{[]string{"dev-tools"}, []string{"pytest", "mypy"}},
+		{[]string{"DEV_tools"}, []string{"pytest", "mypy"}},
+	}
+	for _, tc := range testCases {
+		var got []string
+		for _, d := range whl.Dependencies(tc.extras) {
+			got = append(got, d.Name+formatExtras(d.Extras))
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%v: got: %v, want: %v", tc.extras, got, tc.want)
+		}
+	}
+}
+
+func TestWheelSum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "example-1.0-py3-none-any.whl")
+	if err := ioutil.WriteFile(path, []byte("wheel"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	fileSum := sha256.Sum256([]byte("wheel"))
+	lockedSum := sha256.Sum256([]byte("locked"))
+
+	// The verified digest of the lock identifies the installation.
+	whl := &Wheel{Path: path, Hashes: map[string]string{"sha256": hex.EncodeToString(lockedSum[:])}}
+	sum, err := whl.sum()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(sum, lockedSum[:]) {
+		t.Fatalf("got: %x, want: %x", sum, lockedSum)
+	}
+
+	whl.Hashes = nil
+	sum, err = whl.sum()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(sum, fileSum[:]) {
+		t.Fatalf("got: %x, want: %x", sum, fileSum)
+	}
+
+	whl.Hashes = map[string]string{"sha256": "invalid"}
+	if _, err := whl.sum(); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Why explains why the package identified by name is part of the build list by
+// printing every path from a direct dependency in rope.json to the package.
+// Each step in a path is labelled with the requirement that caused it.
+func Why(ctx context.Context, output io.Writer, name string) error {
+	project, err := ReadRopefile()
+	if err != nil {
+		return err
+	}
+
+	index, err := project.PackageIndex()
+	if err != nil {
+		return err
+	}
+	list, _, err := MinimalVersionSelection(ctx, project.Dependencies, index)
+	if err != nil {
+		return fmt.Errorf("failed version selection: %w", err)
+	}
+
+	graph, err := NewGraph(ctx, project.Dependencies, list, index)
+	if err != nil {
+		return err
+	}
+
+	return writeWhy(output, graph, NormalizePackageName(name))
+}
+
+// edge is a single step in a path through the dependency graph.
+type edge struct {
+	// dependant is empty when the dependency is declared in rope.json.
+	dependant  string
+	dependency Dependency
+}
+
+func writeWhy(output io.Writer, g *Graph, name string) error {
+	target, ok := g.Nodes[name]
+	if !ok {
+		return fmt.Errorf("'%s' is not a dependency", name)
+	}
+
+	paths, truncated := g.Paths(name)
+	for i, path := range paths {
+		if i > 0 {
+			fmt.Fprintln(output)
+		}
+		writePath(output, g, path)
+	}
+	if truncated {
+		fmt.Fprintf(output, "\n(only the first %d paths are shown)\n", maxPaths)
+	}
+
+	fmt.Fprintln(output)
+	writeSelected(output, target)
+	return nil
+}
+
+// writePath writes a path from a direct dependency with every step labelled
+// with the requirement that caused it.
+func writePath(output io.Writer, g *Graph, path []edge) {
+	for j, e := range path {
+		node := g.Nodes[e.dependency.Name]
+		if j == 0 {
+			fmt.Fprintf(output, "%s %s (rope.json)\n", node.Name, node.Version.Canonical())
+			continue
+		}
+		fmt.Fprintf(output, "%*s└── %s %s (%s)\n", (j-1)*4, "", node.Name, node.Version.Canonical(), requirementLabel(e.dependency))
+	}
+}
+
+// writeSelected writes the requirements that forced the selected version of
+// target as recorded by minimal version selection. These include the
+// requirements of versions that were visited but later replaced, which are
+// not part of the graph.
+func writeSelected(output io.Writer, target Dependency) {
+	fmt.Fprintf(output, "%s %s selected due to:\n", target.Name, target.Version.Canonical())
+	for _, s := range target.SelectedBy {
+		fmt.Fprintf(output, "  %s\n", s)
+	}
+	if target.Mismatch {
+		fmt.Fprintf(output, "  (no release matched the requested version exactly)\n")
+	}
+}
+
+// maxPaths is the maximum number of paths returned by Paths. The number of
+// paths to a package may grow exponentially with the depth of the graph.
+const maxPaths = 20
+
+// Paths returns the paths from a direct dependency to the package identified
+// by name. Cycles are not followed and only packages from which the package
+// can be reached are visited. At most maxPaths paths are returned, the second
+// return value is true if any path was omitted.
+func (g *Graph) Paths(name string) ([][]edge, bool) {
+	// Find every package from which name can be reached by walking the
+	// edges in reverse.
+	reverse := make(map[string][]string)
+	for dependant, edges := range g.Edges {
+		for _, d := range edges {
+			reverse[d.Name] = append(reverse[d.Name], dependant)
+		}
+	}
+	reaches := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, dependant := range reverse[current] {
+			if !reaches[dependant] {
+				reaches[dependant] = true
+				queue = append(queue, dependant)
+			}
+		}
+	}
+
+	var paths [][]edge
+	truncated := false
+	onPath := make(map[string]bool)
+
+	var walk func(path []edge)
+	walk = func(path []edge) {
+		if truncated {
+			return
+		}
+		current := path[len(path)-1].dependency.Name
+		if current == name {
+			if len(paths) == maxPaths {
+				truncated = true
+				return
+			}
+			paths = append(paths, append([]edge{}, path...))
+			return
+		}
+
+		onPath[current] = true
+		for _, d := range g.Edges[current] {
+			if onPath[d.Name] || !reaches[d.Name] {
+				continue
+			}
+			walk(append(path, edge{dependant: current, dependency: d}))
+		}
+		onPath[current] = false
+	}
+
+	for _, d := range sortedDependencies(g.Roots) {
+		if reaches[d.Name] {
+			walk([]edge{{dependency: d}})
+		}
+	}
+
+	return paths, truncated
+}
+
+// requirementLabel returns the requirement that introduced the dependency.
+func requirementLabel(d Dependency) string {
+	if d.Requirement != "" {
+		return fmt.Sprintf("'%s'", d.Requirement)
+	}
+	if d.Version.Unspecified() {
+		return fmt.Sprintf("'%s'", d.Name)
+	}
+	return fmt.Sprintf("'%s>=%s'", d.Name, d.Version.Canonical())
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/AlexanderEkdahl/rope/version"
+)
+
+func TestWriteWhy(t *testing.T) {
+	graph := &Graph{
+		Roots: []Dependency{
+			{Name: "a", Version: version.MustParse("1.0")},
+			{Name: "b", Version: version.MustParse("1.0")},
+		},
+		Nodes: map[string]Dependency{
+			"a": {Name: "a", Version: version.MustParse("1.0")},
+			"b": {Name: "b", Version: version.MustParse("1.0")},
+			"c": {Name: "c", Version: version.MustParse("1.2"), SelectedBy: []string{"b 1.0 requires 'c (>=1.2,<2)'"}},
+			"d": {Name: "d", Version: version.MustParse("3.1"), SelectedBy: []string{"c 1.2 requires 'd>=3.1'"}},
+		},
+		Edges: map[string][]Dependency{
+			"a": {{Name: "c", Version: version.MustParse("1.1"), Requirement: "c>=1.1"}},
+			"b": {{Name: "c", Version: version.MustParse("1.2"), Requirement: "c (>=1.2,<2)"}},
+			"c": {{Name: "d", Version: version.MustParse("3.1"), Requirement: "d>=3.1"}},
+		},
+	}
+
+	var sb strings.Builder
+	if err := writeWhy(&sb, graph, "d"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `a 1.0 (rope.json)
+└── c 1.2 ('c>=1.1')
+    └── d 3.1 ('d>=3.1')
+
+b 1.0 (rope.json)
+└── c 1.2 ('c (>=1.2,<2)')
+    └── d 3.1 ('d>=3.1')
+
+d 3.1 selected due to:
+  c 1.2 requires 'd>=3.1'
+`
+	if sb.String() != expected {
+		t.Fatalf("unexpected output, got:\n%s\nwant:\n%s", sb.String(), expected)
+	}
+
+	sb.Reset()
+	if err := writeWhy(&sb, graph, "c"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(sb.String(), "c 1.2 selected due to:\n  b 1.0 requires 'c (>=1.2,<2)'\n") {
+		t.Fatalf("unexpected output, got:\n%s", sb.String())
+	}
+}
+
+func TestPathsTruncated(t *testing.T) {
+	// Every level doubles the number of paths to the last package.
+	const depth = 40
+	graph := &Graph{
+		Roots: []Dependency{{Name: "root", Version: version.MustParse("1.0")}},
+		Nodes: map[string]Dependency{},
+		Edges: map[string][]Dependency{},
+	}
+	previous := []string{"root"}
+	for i := 0; i < depth; i++ {
+		level := []string{fmt.Sprintf("a%d", i), fmt.Sprintf("b%d", i)}
+		for _, p := range previous {
+			for _, name := range level {
+				graph.Edges[p] = append(graph.Edges[p], Dependency{Name: name})
+			}
+		}
+		previous = level
+	}
+	for _, p := range previous {
+		graph.Edges[p] = append(graph.Edges[p], Dependency{Name: "target"})
+	}
+	// Packages not leading to the target are not visited.
+	graph.Edges["root"] = append(graph.Edges["root"], Dependency{Name: "unrelated"})
+
+	paths, truncated := graph.Paths("target")
+	if len(paths) != maxPaths || !truncated {
+		t.Fatalf("got: %d paths, truncated: %v, want: %d paths, truncated: true", len(paths), truncated, maxPaths)
+	}
+
+	paths, truncated = graph.Paths("a0")
+	if len(paths) != 1 || truncated {
+		t.Fatalf("got: %d paths, truncated: %v, want: 1 path", len(paths), truncated)
+	}
+}